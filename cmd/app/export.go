@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"prodLoaderREST/internal/config"
+	"prodLoaderREST/internal/services/exporter"
+	"prodLoaderREST/internal/storage/sqlite"
+)
+
+const exportUsage = "usage: app export csv|json|yml file"
+
+// export runs the export subcommand. The catalogue is written to a file,
+// stdout is taken by the logger.
+func export(ctx context.Context, log *slog.Logger, cfg *config.Config, args []string) error {
+	if len(args) != 2 {
+		return errors.New(exportUsage)
+	}
+
+	format := args[0]
+
+	if _, err := exporter.ContentType(format); err != nil {
+		return errors.New(exportUsage)
+	}
+
+	storage, err := sqlite.New(log, cfg.DbPath)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := newExporter(storage, cfg).Export(ctx, format, file); err != nil {
+		return err
+	}
+
+	log.Info("Products exported", "format", format, "file", args[1])
+
+	return file.Close()
+}
+
+func newExporter(storage exporter.Storage, cfg *config.Config) *exporter.Exporter {
+	return exporter.New(storage, exporter.YMLOptions{
+		ShopName: cfg.YmlShopName,
+		Company:  cfg.YmlCompany,
+		URL:      cfg.YmlURL,
+		Currency: cfg.YmlCurrency,
+		Category: cfg.YmlCategory,
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"prodLoaderREST/internal/config"
+	"prodLoaderREST/internal/services/consumer/vk"
+	"prodLoaderREST/internal/storage/sqlite"
+)
+
+// importVK runs the import-vk subcommand: the items of the vk market that are
+// not linked to products are saved as new products.
+func importVK(ctx context.Context, log *slog.Logger, cfg *config.Config) error {
+	storage, err := sqlite.New(log, cfg.DbPath)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return err
+	}
+
+	pictures, err := newPictureManager(storage, fetcher, cfg)
+	if err != nil {
+		return err
+	}
+
+	// reconciliation is not run, so nothing is published
+	consumer := vk.New(log, newVKClient(cfg), cfg.VkGroupID, storage, pictures, fetcher, nil, vk.UploadOptions{}, vk.ReconcileOptions{})
+
+	batch, err := consumer.ImportMarket(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	log.Info("VK market imported", "batchID", batch.ID, "items", batch.Total, "imported", batch.Imported, "failed", batch.Failed)
+
+	return nil
+}
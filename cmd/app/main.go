@@ -9,23 +9,52 @@ import (
 	"prodLoaderREST/internal/broker"
 	"prodLoaderREST/internal/config"
 	"prodLoaderREST/internal/logger"
+	"prodLoaderREST/internal/services/consumer/avito"
+	"prodLoaderREST/internal/services/consumer/ucoz"
 	"prodLoaderREST/internal/services/consumer/vk"
 	"prodLoaderREST/internal/services/productManager"
+	"prodLoaderREST/internal/services/telegram"
+	"prodLoaderREST/internal/services/webhook"
 	"prodLoaderREST/internal/storage/sqlite"
+	"sync"
 	"syscall"
 	"time"
 
-	vkApi "github.com/SevereCloud/vksdk/v3/api"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	//ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	cfg := config.MustRead()
 
 	log := logger.New(cfg.Log)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(ctx, log, cfg.DbPath, os.Args[2:]); err != nil {
+			log.Error("Migration failed", "err", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := export(ctx, log, cfg, os.Args[2:]); err != nil {
+			log.Error("Export failed", "err", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "import-vk" {
+		if err := importVK(ctx, log, cfg); err != nil {
+			log.Error("VK import failed", "err", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	log.Info("App is starting")
 
 	gin.SetMode(gin.ReleaseMode)
@@ -36,15 +65,160 @@ func main() {
 		return
 	}
 
-	Exchanger := broker.New(log, storage)
+	// no batch is added before the API is up, the processing ones were left by a previous run
+	if n, err := storage.InterruptImports(ctx); err != nil {
+		log.Error("Failed to mark unfinished imports", "err", err.Error())
+	} else if n > 0 {
+		log.Warn("unfinished imports interrupted", "count", n)
+	}
 
-	productManager := productManager.New(log, vk.New(log, vkApi.NewVK(cfg.VkToken), cfg.VkGroupID, storage), Exchanger, storage)
+	webhooks := webhook.Options{
+		URLs:         cfg.WebhookURLs,
+		Secret:       cfg.WebhookSecret,
+		Events:       cfg.WebhookEvents,
+		Timeout:      cfg.WebhookTimeout,
+		PollInterval: cfg.WebhookPollInterval,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		BackoffBase:  cfg.WebhookBackoffBase,
+		BackoffMax:   cfg.WebhookBackoffMax,
+	}
 
-	productManager.Listen()
+	if err := webhooks.Validate(); err != nil {
+		log.Error("Invalid webhook options", "err", err.Error())
+		return
+	}
 
-	log.Info("Autharizated vk:", "Name:", productManager.VK.GetClientName())
+	notifier := webhook.New(log, storage, webhooks)
 
-	API := api.New(log, productManager, Exchanger, storage)
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		log.Error("Invalid fetch options", "err", err.Error())
+		return
+	}
+
+	pictures, err := newPictureManager(storage, fetcher, cfg)
+	if err != nil {
+		log.Error("Invalid picture store options", "err", err.Error())
+		return
+	}
+
+	jobs := broker.Options{
+		PollInterval: cfg.JobPollInterval,
+		LeaseTimeout: cfg.JobLeaseTimeout,
+		MaxAttempts:  cfg.JobMaxAttempts,
+		BackoffBase:  cfg.JobBackoffBase,
+		BackoffMax:   cfg.JobBackoffMax,
+	}
+
+	if err := jobs.Validate(); err != nil {
+		log.Error("Invalid job options", "err", err.Error())
+		return
+	}
+
+	Exchanger := broker.New(log, storage, notifier, pictures, jobs)
+
+	productManager := productManager.New(log, Exchanger, storage)
+
+	vkReconcile := vk.ReconcileOptions{
+		Interval: cfg.VkReconcileInterval,
+		Missing:  cfg.VkReconcileMissing,
+		Push:     cfg.VkReconcilePush,
+		Adopt:    cfg.VkReconcileAdopt,
+	}
+
+	if err := vkReconcile.Validate(); err != nil {
+		log.Error("Invalid vk reconciliation options", "err", err.Error())
+		return
+	}
+
+	vkUpload := vk.UploadOptions{
+		Products: cfg.VkWorkers,
+		Pictures: cfg.VkPictureWorkers,
+	}
+
+	if err := vkUpload.Validate(); err != nil {
+		log.Error("Invalid vk upload options", "err", err.Error())
+		return
+	}
+
+	vkConsumer := vk.New(log, newVKClient(cfg), cfg.VkGroupID, storage, pictures, fetcher, Exchanger, vkUpload, vkReconcile)
+
+	err = productManager.Register(vkConsumer)
+	if err != nil {
+		log.Error("Failed to register vk", "err", err.Error())
+		return
+	}
+
+	if cfg.UcozAPIURL != "" {
+		ucozClient := ucoz.NewClient(log, cfg.UcozConsumerKey, cfg.UcozConsumerSecret, cfg.UcozToken, cfg.UcozTokenSecret)
+
+		err = productManager.Register(ucoz.New(log, ucozClient, cfg.UcozAPIURL, storage, fetcher))
+		if err != nil {
+			log.Error("Failed to register ucoz", "err", err.Error())
+			return
+		}
+	}
+
+	var avitoClient *avito.Client
+	if cfg.AvitoClientID != "" {
+		avitoClient = avito.NewClient(cfg.AvitoAPIURL, cfg.AvitoClientID, cfg.AvitoClientSecret)
+	}
+
+	err = productManager.Register(avito.New(log, storage, avitoClient, avito.FeedOptions{
+		Category:     cfg.AvitoCategory,
+		GoodsType:    cfg.AvitoGoodsType,
+		AdType:       cfg.AvitoAdType,
+		Condition:    cfg.AvitoCondition,
+		Address:      cfg.AvitoAddress,
+		ContactPhone: cfg.AvitoContactPhone,
+	}, cfg.AvitoReportInterval))
+	if err != nil {
+		log.Error("Failed to register avito", "err", err.Error())
+		return
+	}
+
+	// background tracks the dispatcher, the notifier and the bot, they stop
+	// once ctx is cancelled
+	var background sync.WaitGroup
+
+	goBackground := func(run func(context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(ctx)
+		}()
+	}
+
+	var telegramClient *telegram.Client
+
+	if cfg.TelegramToken != "" {
+		telegramClient = telegram.NewClient(cfg.TelegramAPIURL, cfg.TelegramToken, cfg.TelegramPollTimeout)
+
+		bot, err := newTelegramBot(log, telegramClient, Exchanger, cfg)
+		if err != nil {
+			log.Error("Invalid telegram options", "err", err.Error())
+			return
+		}
+
+		goBackground(bot.Run)
+	}
+
+	productManager.Listen(ctx)
+
+	goBackground(Exchanger.Run)
+
+	goBackground(notifier.Run)
+
+	for name, err := range productManager.Health(ctx) {
+		if err != nil {
+			log.Warn("Marketplace is unhealthy", "name", name, "err", err.Error())
+			continue
+		}
+
+		log.Info("Marketplace is healthy", "name", name)
+	}
+
+	API := api.New(log, productManager, Exchanger, storage, newExporter(storage, cfg), notifier, telegramClient, pictures)
 	API.Setup()
 
 	srv := http.Server{
@@ -84,10 +258,10 @@ func main() {
 	case sig := <-shutdown:
 		log.Error("received signal, starting graceful shutdown", "signal", sig)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+		shutdownCtx, stop := context.WithTimeout(context.Background(), 30*time.Second)
+		defer stop()
 
-		if err := srv.Shutdown(ctx); err != nil {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
 			log.Error("server graceful shutdown failed", "err", err)
 			err = srv.Close()
 			if err != nil {
@@ -95,6 +269,26 @@ func main() {
 			}
 		}
 
+		if err := API.Importer.Wait(shutdownCtx); err != nil {
+			log.Error("imports are not finished", "err", err)
+		}
+
+		if err := vkConsumer.WaitImports(shutdownCtx); err != nil {
+			log.Error("vk market imports are not finished", "err", err)
+		}
+
+		// the dispatcher and the workers save job results until they return,
+		// the storage is closed after them
+		cancel()
+
+		if err := productManager.Wait(shutdownCtx); err != nil {
+			log.Error("marketplace workers are not stopped", "err", err)
+		}
+
+		if err := wait(shutdownCtx, &background); err != nil {
+			log.Error("background work is not stopped", "err", err)
+		}
+
 		storage.Close()
 
 		log.Info("shutdown completed")
@@ -102,3 +296,20 @@ func main() {
 	}
 
 }
+
+// wait blocks until wg is done or ctx is cancelled.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
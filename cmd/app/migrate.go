@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"prodLoaderREST/internal/storage/sqlite"
+	"strconv"
+)
+
+const migrateUsage = "usage: app migrate up [version] | down [version] | status"
+
+// migrate runs the migrate subcommand: up and down move the schema to the
+// version (the latest one and none by default), status prints the current version.
+func migrate(ctx context.Context, log *slog.Logger, dbPath string, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+
+	var target int
+
+	if len(args) == 2 {
+		var err error
+
+		target, err = strconv.Atoi(args[1])
+		if err != nil || target < 0 {
+			return fmt.Errorf("invalid version %q: %s", args[1], migrateUsage)
+		}
+	}
+
+	migrator, err := sqlite.NewMigrator(log, dbPath)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx, target)
+	case "down":
+		err = migrator.Down(ctx, target)
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Database schema", "version", version, "latest", migrator.Latest())
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"prodLoaderREST/internal/config"
+	"prodLoaderREST/internal/lib/fetcher"
+	"prodLoaderREST/internal/services/telegram"
+	"prodLoaderREST/internal/storage/pictureManager"
+	"slices"
+	"strings"
+)
+
+// newFetcher builds the fetcher of picture URLs. The uploaded and telegram
+// pictures of this server are always trusted, products reference them by URL
+// and the server is usually on a private address.
+func newFetcher(cfg *config.Config) (*fetcher.Fetcher, error) {
+	opts := fetcher.Options{
+		Timeout:      cfg.FetchTimeout,
+		MaxSize:      cfg.FetchMaxSize,
+		MaxRedirects: cfg.FetchMaxRedirects,
+		PerHost:      cfg.FetchPerHost,
+		TrustedURLs: append(slices.Clone(cfg.FetchTrustedURLs),
+			strings.TrimRight(publicURL(cfg), "/")+"/pictures/",
+			strings.TrimRight(telegramFilesURL(cfg), "/")+telegram.FilesPath,
+		),
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return fetcher.New(opts), nil
+}
+
+// newPictureManager builds the picture manager with the store chosen by
+// PICTURES_STORE: "fs" keeps pictures in PICTURES_PATH, "s3" in a bucket.
+func newPictureManager(storage pictureManager.Storage, fetcher pictureManager.Fetcher, cfg *config.Config) (*pictureManager.Manager, error) {
+	switch cfg.PicturesStore {
+	case "fs":
+		return pictureManager.New(pictureManager.NewFileStore(cfg.PicturesPath), storage, fetcher, publicURL(cfg)), nil
+	case "s3":
+		opts := pictureManager.S3Options{
+			Endpoint:  cfg.PicturesS3Endpoint,
+			Region:    cfg.PicturesS3Region,
+			Bucket:    cfg.PicturesS3Bucket,
+			AccessKey: cfg.PicturesS3AccessKey,
+			SecretKey: cfg.PicturesS3SecretKey,
+			Timeout:   cfg.PicturesS3Timeout,
+		}
+
+		if err := opts.Validate(); err != nil {
+			return nil, err
+		}
+
+		return pictureManager.New(pictureManager.NewS3Store(opts), storage, fetcher, publicURL(cfg)), nil
+	default:
+		return nil, fmt.Errorf("unknown picture store: %s", cfg.PicturesStore)
+	}
+}
+
+// publicURL is the base URL of the API as marketplaces see it, this server on
+// localhost without PUBLIC_URL.
+func publicURL(cfg *config.Config) string {
+	if cfg.PublicURL != "" {
+		return cfg.PublicURL
+	}
+
+	host := cfg.ServerHost
+	if host == "" {
+		host = "localhost"
+	}
+
+	return fmt.Sprintf("http://%s:%s/api/v1", host, cfg.ServerPort)
+}
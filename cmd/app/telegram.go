@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"prodLoaderREST/internal/config"
+	"prodLoaderREST/internal/services/telegram"
+)
+
+// newTelegramBot builds the bot from the config. Without TELEGRAM_FILES_URL
+// the pictures are served by this server at PUBLIC_URL.
+func newTelegramBot(log *slog.Logger, client *telegram.Client, exchanger telegram.Exchanger, cfg *config.Config) (*telegram.Bot, error) {
+	vkCategories, err := telegram.ParseCategories(cfg.TelegramVKCategories)
+	if err != nil {
+		return nil, fmt.Errorf("vk categories: %w", err)
+	}
+
+	ucozCategories, err := telegram.ParseCategories(cfg.TelegramUcozCategories)
+	if err != nil {
+		return nil, fmt.Errorf("ucoz categories: %w", err)
+	}
+
+	opts := telegram.Options{
+		AllowedUsers:   cfg.TelegramAllowedUsers,
+		PollTimeout:    cfg.TelegramPollTimeout,
+		FilesURL:       telegramFilesURL(cfg),
+		VKCategories:   vkCategories,
+		UcozCategories: ucozCategories,
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return telegram.New(log, client, exchanger, opts), nil
+}
+
+// telegramFilesURL is the root the telegram pictures are served at, this
+// server without TELEGRAM_FILES_URL.
+func telegramFilesURL(cfg *config.Config) string {
+	if cfg.TelegramFilesURL != "" {
+		return cfg.TelegramFilesURL
+	}
+
+	return publicURL(cfg)
+}
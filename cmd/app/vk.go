@@ -0,0 +1,17 @@
+package main
+
+import (
+	"prodLoaderREST/internal/config"
+
+	vkApi "github.com/SevereCloud/vksdk/v3/api"
+)
+
+// newVKClient builds the VK API client, the SDK waits between calls to make
+// no more than VK_RATE_LIMIT of them a second, 0 turns the limit off. VK allows
+// 3 for a user token.
+func newVKClient(cfg *config.Config) *vkApi.VK {
+	client := vkApi.NewVK(cfg.VkToken)
+	client.Limit = cfg.VkRateLimit
+
+	return client
+}
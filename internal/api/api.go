@@ -4,14 +4,36 @@ import (
 	"log/slog"
 
 	"prodLoaderREST/internal/broker"
+	"prodLoaderREST/internal/domain/models"
 	"prodLoaderREST/internal/lib/api/log"
+	"prodLoaderREST/internal/services/exporter"
+	"prodLoaderREST/internal/services/importer"
 	"prodLoaderREST/internal/services/productManager"
+	"prodLoaderREST/internal/services/telegram"
+	"prodLoaderREST/internal/services/webhook"
 	"prodLoaderREST/internal/storage"
+	"prodLoaderREST/internal/storage/pictureManager"
 
+	"prodLoaderREST/internal/api/handlers/avito/feed"
+	"prodLoaderREST/internal/api/handlers/health"
+	"prodLoaderREST/internal/api/handlers/jobs/dead"
+	"prodLoaderREST/internal/api/handlers/jobs/job"
+	"prodLoaderREST/internal/api/handlers/jobs/redrive"
+	"prodLoaderREST/internal/api/handlers/pictures/upload"
 	"prodLoaderREST/internal/api/handlers/product/add"
+	"prodLoaderREST/internal/api/handlers/product/bulk"
 	deleteHandler "prodLoaderREST/internal/api/handlers/product/delete"
+	"prodLoaderREST/internal/api/handlers/product/export"
 	"prodLoaderREST/internal/api/handlers/product/get"
+	"prodLoaderREST/internal/api/handlers/product/item"
+	"prodLoaderREST/internal/api/handlers/product/jobs"
 	"prodLoaderREST/internal/api/handlers/product/pic"
+	"prodLoaderREST/internal/api/handlers/product/update"
+	"prodLoaderREST/internal/api/handlers/telegram/file"
+	"prodLoaderREST/internal/api/handlers/vk/market"
+	"prodLoaderREST/internal/api/handlers/vk/reconcile"
+	"prodLoaderREST/internal/api/handlers/webhooks/deliveries"
+	"prodLoaderREST/internal/api/handlers/webhooks/redeliver"
 	"prodLoaderREST/internal/api/middlewares/requestid"
 
 	"github.com/gin-gonic/gin"
@@ -24,15 +46,25 @@ type API struct {
 	productManager *productManager.Manager
 	Exchanger      *broker.Exchanger
 	Storage        storage.Storage
+	Exporter       *exporter.Exporter
+	Notifier       *webhook.Notifier
+	Telegram       *telegram.Client
+	Pictures       *pictureManager.Manager
+	Importer       *importer.Importer
 }
 
-func New(log *slog.Logger, productManager *productManager.Manager, Exchanger *broker.Exchanger, storage storage.Storage) *API {
+func New(log *slog.Logger, productManager *productManager.Manager, Exchanger *broker.Exchanger, storage storage.Storage, exporter *exporter.Exporter, notifier *webhook.Notifier, telegram *telegram.Client, pictures *pictureManager.Manager) *API {
 	return &API{
 		Router:         gin.New(),
 		Log:            log,
 		productManager: productManager,
 		Exchanger:      Exchanger,
 		Storage:        storage,
+		Exporter:       exporter,
+		Notifier:       notifier,
+		Telegram:       telegram,
+		Pictures:       pictures,
+		Importer:       importer.New(log, Exchanger, storage),
 	}
 }
 
@@ -42,10 +74,51 @@ func (api *API) Setup() {
 	v1.Use(requestid.RequestIdMidlleware())
 	v1.Use(gin.LoggerWithFormatter(log.Logging))
 
-	v1.POST("/products", add.New(api.Log, api.Exchanger))
+	v1.POST("/products", add.New(api.Log, api.Exchanger, api.Pictures))
+	v1.PUT("/products/:id", update.New(api.Log, api.Exchanger))
+	v1.PATCH("/products/:id", update.New(api.Log, api.Exchanger))
 	v1.DELETE("/products/", deleteHandler.New(api.Log, api.Exchanger))
+	v1.DELETE("/products/:id", deleteHandler.New(api.Log, api.Exchanger))
 	v1.GET("/products/", get.New(api.Log, api.Storage))
-	v1.GET("/products/picture/:id", pic.New(api.Log))
+	v1.GET("/products/:id", item.New(api.Log, api.Storage))
+	v1.GET("/products/:id/jobs", jobs.New(api.Log, api.Storage))
+	v1.GET("/products/picture/:id", pic.New(api.Log, api.Pictures))
+	v1.GET("/products/:id/pictures/:n", pic.NewByIndex(api.Log, api.Pictures))
+
+	v1.POST("/pictures", upload.New(api.Log, api.Pictures))
+	v1.GET("/pictures/:id", pic.NewByID(api.Log, api.Pictures))
+	v1.POST("/products/import", bulk.New(api.Log, api.Importer))
+	v1.GET("/products/import/:id", bulk.NewBatch(api.Log, api.Storage))
+	v1.GET("/products/export", export.New(api.Log, api.Exporter))
+
+	v1.GET("/jobs/dead", dead.New(api.Log, api.Storage))
+	v1.GET("/jobs/:id", job.New(api.Log, api.Storage))
+	v1.POST("/jobs/dead/:id/redrive", redrive.New(api.Log, api.Exchanger))
+
+	v1.GET("/webhooks/deliveries", deliveries.New(api.Log, api.Storage))
+	v1.POST("/webhooks/deliveries/:id/redeliver", redeliver.New(api.Log, api.Notifier))
+
+	v1.GET("/health", health.New(api.Log, api.productManager))
+
+	if api.Telegram != nil {
+		v1.GET(telegram.FilesPath+":file", file.New(api.Log, api.Telegram))
+	}
+
+	if avito, ok := api.productManager.Marketplace(models.PlatformAvito); ok {
+		if writer, ok := avito.(feed.FeedWriter); ok {
+			v1.GET("/avito/feed.xml", feed.New(api.Log, writer))
+		}
+	}
+
+	if vk, ok := api.productManager.Marketplace(models.PlatformVK); ok {
+		if reconciler, ok := vk.(reconcile.Reconciler); ok {
+			v1.POST("/vk/reconcile", reconcile.New(api.Log, reconciler))
+		}
+
+		if importer, ok := vk.(market.Importer); ok {
+			v1.POST("/vk/import", market.New(api.Log, importer))
+		}
+	}
 
 	v1.GET("/swagger/*any", gin.WrapH(httpSwagger.Handler()))
 
@@ -0,0 +1,36 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/lib/api/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeedWriter interface {
+	WriteFeed(ctx context.Context, w io.Writer) error
+}
+
+func New(log *slog.Logger, writer FeedWriter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		var buf bytes.Buffer
+
+		if err := writer.WriteFeed(c.Request.Context(), &buf); err != nil {
+			logHandler.Error("failed to write avito feed", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		c.Data(http.StatusOK, "application/xml; charset=utf-8", buf.Bytes())
+	}
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/lib/api/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthChecker interface {
+	Health(ctx context.Context) map[string]error
+}
+
+type MarketplaceHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// New returns the health of every enabled marketplace, the status is 503 when any of them is unhealthy.
+func New(log *slog.Logger, checker HealthChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		status := http.StatusOK
+
+		marketplaces := make([]MarketplaceHealth, 0)
+
+		for name, err := range checker.Health(c.Request.Context()) {
+			health := MarketplaceHealth{Name: name, Healthy: err == nil}
+
+			if err != nil {
+				logHandler.Warn("marketplace is unhealthy", "name", name, "err", err.Error())
+
+				health.Error = err.Error()
+				status = http.StatusServiceUnavailable
+			}
+
+			marketplaces = append(marketplaces, health)
+		}
+
+		c.JSON(status, response.OKWithPayload(marketplaces))
+	}
+}
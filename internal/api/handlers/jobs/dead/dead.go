@@ -0,0 +1,79 @@
+package dead
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeadJobsLister interface {
+	DeadJobs(ctx context.Context, offset int, limit int) (jobs []*models.Job, count int, err error)
+}
+
+const defaultPage = "1"
+const defaultLimit = "10"
+
+func New(log *slog.Logger, lister DeadJobsLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		var pag types.Pagination
+
+		var err error
+
+		pageQuery := c.DefaultQuery("page", defaultPage)
+
+		pag.Page, err = strconv.Atoi(pageQuery)
+		if err != nil || pag.Page < 1 {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "page", "query", pageQuery)
+
+			c.JSON(http.StatusBadRequest, response.Error(fmt.Sprintf("Invalid parameter:%s", pageQuery)))
+
+			return
+		}
+
+		limitQuery := c.DefaultQuery("limit", defaultLimit)
+
+		pag.Limit, err = strconv.Atoi(limitQuery)
+		if err != nil || pag.Limit < 1 {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "limit", "query", limitQuery)
+
+			c.JSON(http.StatusBadRequest, response.Error(fmt.Sprintf("Invalid parameter:%s", limitQuery)))
+
+			return
+		}
+
+		jobs, count, err := lister.DeadJobs(ctx, pag.Offset(), pag.Limit)
+		if err != nil {
+			logHandler.Error("can't get list of dead jobs", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Server Error"))
+			return
+		}
+
+		if jobs == nil {
+			jobs = []*models.Job{}
+		}
+
+		meta := &types.Meta{
+			Total:  count,
+			Limit:  pag.Limit,
+			Offset: pag.Offset(),
+			Next:   (pag.Offset() + pag.Limit) < count,
+		}
+
+		c.JSON(http.StatusOK, response.OKWithPayload(map[string]interface{}{"data": jobs, "meta": meta}))
+	}
+}
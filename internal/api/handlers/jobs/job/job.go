@@ -0,0 +1,57 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobGetter interface {
+	Job(ctx context.Context, jobID int64) (*models.Job, error)
+}
+
+// New returns the job with its stage on the marketplace, the attempts made and
+// the last error.
+func New(log *slog.Logger, getter JobGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		idParam := c.Param("id")
+
+		jobID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "id", "query", idParam)
+
+			c.JSON(http.StatusBadRequest, response.Error("job ID is not integer"))
+			return
+		}
+
+		job, err := getter.Job(c.Request.Context(), jobID)
+		if err != nil {
+			if errors.Is(err, storage.ErrJobNotFound) {
+				logHandler.Error("job not found", "jobID", jobID)
+
+				c.JSON(http.StatusNotFound, response.Error("job not found"))
+				return
+			}
+
+			logHandler.Error("failed to get job", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		c.JSON(http.StatusOK, response.OKWithPayload(job))
+	}
+}
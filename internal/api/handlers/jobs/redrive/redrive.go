@@ -0,0 +1,56 @@
+package redrive
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobRedriver interface {
+	Redrive(ctx context.Context, jobID int64) error
+}
+
+func New(log *slog.Logger, redriver JobRedriver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		idParam := c.Param("id")
+
+		jobID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "id", "query", idParam)
+
+			c.JSON(http.StatusBadRequest, response.Error("job ID is not integer"))
+			return
+		}
+
+		err = redriver.Redrive(c.Request.Context(), jobID)
+		if err != nil {
+			if errors.Is(err, storage.ErrJobNotFound) {
+				logHandler.Error("dead job not found", "jobID", jobID)
+
+				c.JSON(http.StatusNotFound, response.Error("dead job not found"))
+				return
+			}
+
+			logHandler.Error("failed to redrive job", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		logHandler.Info("job redriven", "jobID", jobID)
+
+		c.JSON(http.StatusOK, response.OK())
+	}
+}
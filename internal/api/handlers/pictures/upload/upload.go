@@ -0,0 +1,58 @@
+package upload
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/lib/api/upload"
+	"prodLoaderREST/internal/storage/pictureManager"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FormField is the multipart field of the uploaded files, one or many.
+const FormField = "pictures"
+
+// New stores the pictures of a multipart form and returns their IDs in the
+// order of the files, products reference them with mainPictureID and pictureIDs.
+func New(log *slog.Logger, uploader upload.Uploader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		form, err := c.MultipartForm()
+		if err != nil {
+			logHandler.Error("failed to read multipart form", "err", err.Error())
+			c.JSON(http.StatusBadRequest, response.Error("multipart form with pictures expected"))
+			return
+		}
+
+		files := form.File[FormField]
+		if len(files) == 0 {
+			logHandler.Error("no pictures uploaded")
+			c.JSON(http.StatusBadRequest, response.Error("field pictures is missed"))
+			return
+		}
+
+		ids, err := upload.Pictures(c.Request.Context(), uploader, files)
+		if err != nil {
+			if errors.Is(err, pictureManager.ErrInvalidPicture) {
+				logHandler.Error("invalid picture", "err", err.Error())
+				c.JSON(http.StatusBadRequest, response.Error(err.Error()))
+				return
+			}
+
+			logHandler.Error("failed to upload pictures", "err", err.Error())
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		logHandler.Info("pictures uploaded", "ids", ids)
+
+		c.JSON(http.StatusOK, response.OKWithPayload(ids))
+	}
+}
@@ -2,23 +2,39 @@ package add
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 
 	"prodLoaderREST/internal/api/middlewares/requestid"
 	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/broker"
 	"prodLoaderREST/internal/domain/models"
 	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/lib/api/upload"
+	"prodLoaderREST/internal/storage/pictureManager"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
 
+// Fields of the multipart variant: the product as JSON and its picture files.
+const (
+	productField     = "product"
+	mainPictureField = "mainPicture"
+	picturesField    = "pictures"
+)
+
 type Exchanger interface {
-	WriteAdd(ctx context.Context, product *models.Product) error
+	WriteAdd(ctx context.Context, product *models.Product) ([]*models.Job, error)
 }
 
-func New(log *slog.Logger, exchanger Exchanger) gin.HandlerFunc {
+// New adds a product from JSON or from a multipart form with the product JSON
+// in the "product" field and the picture files in "mainPicture" and
+// "pictures", the files follow the URLs and IDs of the product.
+func New(log *slog.Logger, exchanger Exchanger, uploader upload.Uploader) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		logHandler := log.With("requestID", requestid.Get(c))
@@ -27,14 +43,42 @@ func New(log *slog.Logger, exchanger Exchanger) gin.HandlerFunc {
 
 		var Product *models.Product
 
-		if err := c.BindJSON(&Product); err != nil {
+		var form *multipart.Form
+
+		if c.ContentType() == gin.MIMEMultipartPOSTForm {
+			var err error
+
+			form, err = c.MultipartForm()
+			if err != nil {
+				logHandler.Error(types.ErrDecodeReqBody.Error(), "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error(types.ErrDecodeReqBody.Error()))
+				return
+			}
+
+			if err := json.Unmarshal([]byte(c.PostForm(productField)), &Product); err != nil || Product == nil {
+				logHandler.Error(types.ErrDecodeReqBody.Error(), "field", productField)
+
+				c.JSON(http.StatusBadRequest, response.Error(types.ErrDecodeReqBody.Error()))
+				return
+			}
+		} else if err := c.BindJSON(&Product); err != nil {
 			logHandler.Error(types.ErrDecodeReqBody.Error(), "err", err.Error())
 
 			c.JSON(http.StatusBadRequest, response.Error(types.ErrDecodeReqBody.Error()))
 			return
 		}
 
-		if err := validator.New().Struct(Product); err != nil {
+		// the product is validated before the files are stored, the main
+		// picture file stands for the main picture URL
+		validate := validator.New().Struct
+		if form != nil && len(form.File[mainPictureField]) > 0 {
+			validate = func(product any) error {
+				return validator.New().StructExcept(product, "MainPictureURL")
+			}
+		}
+
+		if err := validate(Product); err != nil {
 			validatorErr := err.(validator.ValidationErrors)
 
 			logHandler.Error("invalid request", "err", err.Error())
@@ -44,9 +88,34 @@ func New(log *slog.Logger, exchanger Exchanger) gin.HandlerFunc {
 			return
 		}
 
+		if form != nil {
+			if err := uploadPictures(ctx, uploader, form, Product); err != nil {
+				if errors.Is(err, pictureManager.ErrInvalidPicture) {
+					logHandler.Error("invalid picture", "err", err.Error())
+
+					c.JSON(http.StatusBadRequest, response.Error(err.Error()))
+					return
+				}
+
+				logHandler.Error("failed to upload pictures", "err", err.Error())
+
+				c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+				return
+			}
+		}
+
 		logHandler.Debug("received product", "product", Product)
 
-		if err := exchanger.WriteAdd(ctx, Product); err != nil {
+		jobs, err := exchanger.WriteAdd(ctx, Product)
+		if err != nil {
+			if errors.Is(err, broker.ErrUnknownPicture) {
+				logHandler.Error("unknown picture", "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error(err.Error()))
+
+				return
+			}
+
 			logHandler.Error("failed to write to broker", "err", err.Error())
 
 			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
@@ -54,9 +123,30 @@ func New(log *slog.Logger, exchanger Exchanger) gin.HandlerFunc {
 			return
 		}
 
-		logHandler.Info("product added to queue", "product", Product.Title)
+		logHandler.Info("product added to queue", "product", Product.Title, "productID", Product.Id)
 
-		c.JSON(http.StatusOK, response.OK)
+		c.JSON(http.StatusOK, response.OKWithPayload(types.NewProductJobs(Product.Id, jobs)))
 
 	}
 }
+
+// uploadPictures stores the files of the form and adds their IDs to the product.
+func uploadPictures(ctx context.Context, uploader upload.Uploader, form *multipart.Form, product *models.Product) error {
+	if files := form.File[mainPictureField]; len(files) > 0 {
+		ids, err := upload.Pictures(ctx, uploader, files[:1])
+		if err != nil {
+			return err
+		}
+
+		product.MainPictureID = ids[0]
+	}
+
+	ids, err := upload.Pictures(ctx, uploader, form.File[picturesField])
+	if err != nil {
+		return err
+	}
+
+	product.PictureIDs = append(product.PictureIDs, ids...)
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/services/importer"
+	"prodLoaderREST/internal/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxFileSize = 10 << 20
+
+type Importer interface {
+	Import(ctx context.Context, filename string, format string, r io.Reader, dryRun bool) (*models.ImportBatch, error)
+}
+
+type BatchGetter interface {
+	ImportBatch(ctx context.Context, batchID int64) (*models.ImportBatch, error)
+}
+
+// New imports the multipart "file" field. The format is taken from the format
+// query parameter or the file extension, dry_run=true only validates the rows.
+func New(log *slog.Logger, fileImporter Importer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		dryRun := false
+
+		if dryRunQuery := c.Query("dry_run"); dryRunQuery != "" {
+			var err error
+
+			dryRun, err = strconv.ParseBool(dryRunQuery)
+			if err != nil {
+				logHandler.Error("invalid dry_run parameter", "query", dryRunQuery)
+
+				c.JSON(http.StatusBadRequest, response.Error("dry_run is not boolean"))
+				return
+			}
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize)
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			logHandler.Error(types.ErrDecodeReqBody.Error(), "err", err.Error())
+
+			c.JSON(http.StatusBadRequest, response.Error("file is missed or too large"))
+			return
+		}
+
+		format := c.DefaultQuery("format", importer.FormatFromFilename(header.Filename))
+
+		file, err := header.Open()
+		if err != nil {
+			logHandler.Error("failed to open file", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+		defer file.Close()
+
+		batch, err := fileImporter.Import(c.Request.Context(), header.Filename, format, file, dryRun)
+		if err != nil {
+			if errors.Is(err, importer.ErrInvalidFile) {
+				logHandler.Error("failed to parse file", "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error(err.Error()))
+				return
+			}
+
+			logHandler.Error("failed to import file", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		logHandler.Info("file imported", "batchID", batch.ID, "total", batch.Total, "valid", batch.Valid, "dryRun", dryRun)
+
+		status := http.StatusAccepted
+		if dryRun {
+			status = http.StatusOK
+		}
+
+		c.JSON(status, response.OKWithPayload(batch))
+	}
+}
+
+// NewBatch returns the import batch with the result of every row.
+func NewBatch(log *slog.Logger, getter BatchGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		idParam := c.Param("id")
+
+		batchID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "id", "query", idParam)
+
+			c.JSON(http.StatusBadRequest, response.Error("batch ID is not integer"))
+			return
+		}
+
+		batch, err := getter.ImportBatch(c.Request.Context(), batchID)
+		if err != nil {
+			if errors.Is(err, storage.ErrImportBatchNotFound) {
+				logHandler.Error("import batch not found", "batchID", batchID)
+
+				c.JSON(http.StatusNotFound, response.Error("import batch not found"))
+				return
+			}
+
+			logHandler.Error("failed to get import batch", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		c.JSON(http.StatusOK, response.OKWithPayload(batch))
+	}
+}
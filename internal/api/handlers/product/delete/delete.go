@@ -1,47 +1,83 @@
 package delete
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
 	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/broker"
+	"prodLoaderREST/internal/domain/models"
 	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ProductDeleteWriter interface {
-	WriteDelete(productID int) error
+	WriteDelete(ctx context.Context, productID int64, platforms []string) ([]*models.Job, error)
 }
 
+// New unpublishes the product from every marketplace and deletes it, with
+// ?platforms=vk,ucoz it's only unpublished from the listed ones.
 func New(log *slog.Logger, Deleter ProductDeleteWriter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		logHandler := log.With("requestID", c.GetString("requestID"))
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
 
-		var prodIDint int
+		// the old route passes the ID in the query
+		idParam := c.Param("id")
+		if idParam == "" {
+			idParam = c.Query("product_id")
+		}
 
-		var err error
+		productID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil || productID < 1 {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "id", "query", idParam)
 
-		productID := c.Query("product_id")
-		if productID != "" {
-			prodIDint, err = strconv.Atoi(productID)
-			if err != nil {
-				log.Error(types.ErrConvertParam.Error(), "param", "product_ID", "query", productID)
-				c.JSON(http.StatusBadRequest, response.Error("productID is not integer"))
+			c.JSON(http.StatusBadRequest, response.Error("productID is not integer"))
+			return
+		}
 
-				return
-			}
+		var platforms []string
 
+		for _, value := range c.QueryArray("platforms") {
+			for _, platform := range strings.Split(value, ",") {
+				if platform = strings.TrimSpace(platform); platform != "" {
+					platforms = append(platforms, platform)
+				}
+			}
 		}
 
-		err = Deleter.WriteDelete(prodIDint)
+		jobs, err := Deleter.WriteDelete(c.Request.Context(), productID, platforms)
 		if err != nil {
-			log.Error("failed to write to delete", "err", err.Error())
+			switch {
+			case errors.Is(err, storage.ErrProductIDnotFound):
+				logHandler.Error("product not found", "productID", productID)
+
+				c.JSON(http.StatusNotFound, response.Error("product not found"))
+			case errors.Is(err, broker.ErrUnknownPlatform):
+				logHandler.Error("failed to write to delete", "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error(err.Error()))
+			default:
+				logHandler.Error("failed to write to delete", "err", err.Error())
+
+				c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			}
+			return
+		}
 
-			c.JSON(http.StatusBadRequest, response.Error(err.Error()))
+		if len(platforms) > 0 {
+			logHandler.Info("Product unpublished successfully", "productID", productID, "platforms", platforms)
+		} else {
+			logHandler.Info("Product deleted successfully", "productID", productID)
 		}
 
-		logHandler.Info("Product deleted successfully")
-		c.JSON(200, response.OK())
+		c.JSON(http.StatusOK, response.OKWithPayload(types.NewProductJobs(productID, jobs)))
 	}
 }
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/services/exporter"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultFormat = exporter.FormatJSON
+
+type Exporter interface {
+	Export(ctx context.Context, format string, w io.Writer) error
+}
+
+// New streams the whole catalogue in the format of the format query parameter.
+// Once the first product is written the status can't change, so a failure in the
+// middle is only logged and the client gets a truncated file.
+func New(log *slog.Logger, catalogue Exporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		format := c.DefaultQuery("format", defaultFormat)
+
+		contentType, err := exporter.ContentType(format)
+		if err != nil {
+			logHandler.Error("invalid export format", "format", format)
+
+			c.JSON(http.StatusBadRequest, response.Error("format must be csv, json or yml"))
+			return
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+		c.Status(http.StatusOK)
+
+		if err := catalogue.Export(c.Request.Context(), format, c.Writer); err != nil {
+			logHandler.Error("failed to export products", "format", format, "err", err.Error())
+			return
+		}
+
+		logHandler.Info("products exported", "format", format)
+	}
+}
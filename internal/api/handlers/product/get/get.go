@@ -9,15 +9,17 @@ import (
 	"net/http"
 	"prodLoaderREST/internal/api/middlewares/requestid"
 	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/domain/filters"
 	"prodLoaderREST/internal/domain/models"
 	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ProductSearcher interface {
-	Search(ctx context.Context, query string, offset int, limit int) (products []*models.Product, count int, err error)
+	Search(ctx context.Context, options *filters.Options, offset int, limit int) (products []*models.Product, count int, err error)
 }
 
 var ErrConvertParam = errors.New("can't convernt int query parameter")
@@ -35,14 +37,11 @@ func New(log *slog.Logger, searcher ProductSearcher) gin.HandlerFunc {
 
 		var pag types.Pagination
 
-		var err error
-
-		searchQuery := c.Query("search")
-		if searchQuery == "" {
-			logHandler.Error("empty search query")
-
-			c.JSON(http.StatusBadRequest, response.Error("empty search query"))
+		options, err := parseOptions(c)
+		if err != nil {
+			logHandler.Error("invalid filter", "err", err.Error())
 
+			c.JSON(http.StatusBadRequest, response.Error(err.Error()))
 			return
 		}
 
@@ -76,14 +75,22 @@ func New(log *slog.Logger, searcher ProductSearcher) gin.HandlerFunc {
 
 		logHandler.Debug("Pagination query", "page", pag.Page, "limit", pag.Limit)
 
-		products, count, err := searcher.Search(ctx, searchQuery, pag.Offset(), pag.Limit)
+		products, count, err := searcher.Search(ctx, options, pag.Offset(), pag.Limit)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				logHandler.Error("no data found", "query", searchQuery)
+				logHandler.Error("no data found", "query", c.Request.URL.RawQuery)
 
 				c.JSON(http.StatusNoContent, "")
 				return
 			}
+
+			if errors.Is(err, storage.ErrInvalidSearch) {
+				logHandler.Error("invalid search query", "search", options.Search, "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error(storage.ErrInvalidSearch.Error()))
+				return
+			}
+
 			logHandler.Error("can't get list of persons", "err", err.Error())
 
 			c.JSON(http.StatusInternalServerError, response.Error("Internal Server Error"))
@@ -0,0 +1,143 @@
+package get
+
+import (
+	"fmt"
+	"prodLoaderREST/internal/domain/filters"
+	"prodLoaderREST/internal/domain/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02T15:04:05"
+)
+
+// parseOptions reads the filters of the listing from the query:
+// search, product_id, category_id, vk_published, avito_published, ucoz_published,
+// price_min, price_max, created_from, created_to, status, publish_state, platform
+// and sort. Sort is one of price, created_at, title, prefixed with "-" for
+// descending order.
+func parseOptions(c *gin.Context) (*filters.Options, error) {
+	var err error
+
+	options := &filters.Options{
+		Search: c.Query("search"),
+	}
+
+	if options.ProductID, err = queryInt(c, "product_id"); err != nil {
+		return nil, err
+	}
+	if options.CategoryID, err = queryInt(c, "category_id"); err != nil {
+		return nil, err
+	}
+	if options.IsVkPublished, err = queryBool(c, "vk_published"); err != nil {
+		return nil, err
+	}
+	if options.IsAvitoPublished, err = queryBool(c, "avito_published"); err != nil {
+		return nil, err
+	}
+	if options.IsUcozPublished, err = queryBool(c, "ucoz_published"); err != nil {
+		return nil, err
+	}
+	if options.PriceMin, err = queryInt(c, "price_min"); err != nil {
+		return nil, err
+	}
+	if options.PriceMax, err = queryInt(c, "price_max"); err != nil {
+		return nil, err
+	}
+	if options.CreatedFrom, err = queryTime(c, "created_from", false); err != nil {
+		return nil, err
+	}
+	if options.CreatedTo, err = queryTime(c, "created_to", true); err != nil {
+		return nil, err
+	}
+
+	if status, ok := c.GetQuery("status"); ok {
+		options.Status = &status
+	}
+
+	options.PublishState = c.DefaultQuery("publish_state", filters.PublishStateAny)
+
+	switch options.PublishState {
+	case filters.PublishStateAny, filters.PublishStatePublished, filters.PublishStatePending,
+		filters.PublishStateFailed, filters.PublishStateUnpublished:
+	default:
+		return nil, fmt.Errorf("invalid parameter publish_state: %s", options.PublishState)
+	}
+
+	options.Platform = c.Query("platform")
+
+	switch options.Platform {
+	case "", models.PlatformVK, models.PlatformUcoz, models.PlatformAvito:
+	default:
+		return nil, fmt.Errorf("invalid parameter platform: %s", options.Platform)
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		options.Sort = strings.TrimPrefix(sort, "-")
+		options.SortDesc = strings.HasPrefix(sort, "-")
+
+		switch options.Sort {
+		case filters.SortPrice, filters.SortCreatedAt, filters.SortTitle:
+		default:
+			return nil, fmt.Errorf("invalid parameter sort: %s", sort)
+		}
+	}
+
+	return options, nil
+}
+
+func queryInt(c *gin.Context, name string) (*int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameter %s: %s", name, value)
+	}
+
+	return &n, nil
+}
+
+func queryBool(c *gin.Context, name string) (*bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameter %s: %s", name, value)
+	}
+
+	return &b, nil
+}
+
+// queryTime accepts a date or a date with time, a date of the end of a range includes the whole day.
+func queryTime(c *gin.Context, name string, end bool) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(dateTimeLayout, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameter %s: %s", name, value)
+	}
+
+	if end {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+
+	return &t, nil
+}
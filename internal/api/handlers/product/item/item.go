@@ -0,0 +1,56 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProductGetter interface {
+	Product(ctx context.Context, productID int64) (*models.Product, error)
+}
+
+// New returns the product with its state on every marketplace.
+func New(log *slog.Logger, getter ProductGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		idParam := c.Param("id")
+
+		productID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil || productID < 1 {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "id", "query", idParam)
+
+			c.JSON(http.StatusBadRequest, response.Error("product ID is not integer"))
+			return
+		}
+
+		product, err := getter.Product(c.Request.Context(), productID)
+		if err != nil {
+			if errors.Is(err, storage.ErrProductIDnotFound) {
+				logHandler.Error("product not found", "productID", productID)
+
+				c.JSON(http.StatusNotFound, response.Error("product not found"))
+				return
+			}
+
+			logHandler.Error("failed to get product", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Server Error"))
+			return
+		}
+
+		c.JSON(http.StatusOK, response.OKWithPayload(product))
+	}
+}
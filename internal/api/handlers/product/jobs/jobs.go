@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProductJobsGetter interface {
+	Product(ctx context.Context, productID int64) (*models.Product, error)
+	ProductJobs(ctx context.Context, productID int64) ([]*models.Job, error)
+}
+
+// New returns every job of the product, the latest first, so the first job of
+// a marketplace is its current state.
+func New(log *slog.Logger, getter ProductJobsGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		idParam := c.Param("id")
+
+		productID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "id", "query", idParam)
+
+			c.JSON(http.StatusBadRequest, response.Error("productID is not integer"))
+			return
+		}
+
+		if _, err := getter.Product(ctx, productID); err != nil {
+			if errors.Is(err, storage.ErrProductIDnotFound) {
+				logHandler.Error("product not found", "productID", productID)
+
+				c.JSON(http.StatusNotFound, response.Error("product not found"))
+				return
+			}
+
+			logHandler.Error("failed to get product", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		jobs, err := getter.ProductJobs(ctx, productID)
+		if err != nil {
+			logHandler.Error("failed to get product jobs", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		c.JSON(http.StatusOK, response.OKWithPayload(types.NewProductJobs(productID, jobs)))
+	}
+}
@@ -1,52 +1,151 @@
 package pic
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
 	"prodLoaderREST/internal/api/middlewares/requestid"
 	"prodLoaderREST/internal/lib/api/response"
 	"prodLoaderREST/internal/storage/pictureManager"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-func New(log *slog.Logger) gin.HandlerFunc {
+// Cache-Control of the pictures. An uploaded picture never changes, the
+// picture at a position of a product changes with the product, so it's
+// revalidated with the ETag after a while.
+const (
+	cacheImmutable = "public, max-age=31536000, immutable"
+	cachePosition  = "public, max-age=300"
+)
+
+type PictureGetter interface {
+	Open(ctx context.Context, productID int64, n int, width int) (*pictureManager.Object, error)
+	OpenByID(ctx context.Context, id string, width int) (*pictureManager.Object, error)
+}
+
+// New serves the main picture of the product.
+func New(log *slog.Logger, pictures PictureGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		logHandler := log.With(
 			slog.String("requestID", requestid.Get(c)),
 		)
 
-		id := c.Param("id")
-		if id == "" {
-			logHandler.Error("empty product ID")
-			c.JSON(http.StatusBadRequest, response.Error("empty product ID"))
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id < 1 {
+			logHandler.Error("invalid product ID", "id", c.Param("id"))
+			c.JSON(http.StatusBadRequest, response.Error("invalid product ID"))
 			return
 		}
 
-		file, err := pictureManager.Picture(id)
-		if err != nil {
-			if errors.Is(err, pictureManager.ErrNotFound) {
-				logHandler.Error("picture not found", "id", id)
-				c.JSON(http.StatusNotFound, response.Error("Picture not found"))
-				return
-			}
-			logHandler.Error("failed to get picture", "err", err.Error())
-			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+		width, ok := thumbnailWidth(c, logHandler)
+		if !ok {
 			return
 		}
 
-		if file == nil {
-			logHandler.Error("picture not found", "id", id)
-			c.JSON(http.StatusNotFound, response.Error("Picture not found"))
+		obj, err := pictures.Open(c.Request.Context(), id, 0, width)
+
+		serve(c, logHandler, obj, err, cachePosition)
+	}
+}
+
+// NewByIndex serves the n-th picture of the product, 0 is the main one and
+// the rest follow the order of picturesURL.
+func NewByIndex(log *slog.Logger, pictures PictureGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id < 1 {
+			logHandler.Error("invalid product ID", "id", c.Param("id"))
+			c.JSON(http.StatusBadRequest, response.Error("invalid product ID"))
+			return
+		}
+
+		n, err := strconv.Atoi(c.Param("n"))
+		if err != nil || n < 0 {
+			logHandler.Error("invalid picture number", "n", c.Param("n"))
+			c.JSON(http.StatusBadRequest, response.Error("invalid picture number"))
 			return
 		}
 
-		c.Header("Content-Type", "image/jpeg")
-		c.Status(http.StatusOK)
+		width, ok := thumbnailWidth(c, logHandler)
+		if !ok {
+			return
+		}
 
-		c.Writer.Write(file)
+		obj, err := pictures.Open(c.Request.Context(), id, n, width)
 
+		serve(c, logHandler, obj, err, cachePosition)
 	}
 }
+
+// NewByID serves an uploaded picture.
+func NewByID(log *slog.Logger, pictures PictureGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		width, ok := thumbnailWidth(c, logHandler)
+		if !ok {
+			return
+		}
+
+		obj, err := pictures.OpenByID(c.Request.Context(), c.Param("id"), width)
+
+		serve(c, logHandler, obj, err, cacheImmutable)
+	}
+}
+
+// thumbnailWidth reads the width of the thumbnail from the w query, 0 for
+// the picture itself.
+func thumbnailWidth(c *gin.Context, logHandler *slog.Logger) (int, bool) {
+	w := c.Query("w")
+	if w == "" {
+		return 0, true
+	}
+
+	width, err := strconv.Atoi(w)
+	if err != nil || width < 1 {
+		logHandler.Error("invalid thumbnail width", "w", w)
+		c.JSON(http.StatusBadRequest, response.Error("invalid thumbnail width"))
+		return 0, false
+	}
+
+	return width, true
+}
+
+// serve writes the picture with its validators, conditional and range
+// requests are answered by http.ServeContent.
+func serve(c *gin.Context, logHandler *slog.Logger, obj *pictureManager.Object, err error, cacheControl string) {
+	if err != nil {
+		if errors.Is(err, pictureManager.ErrNotFound) {
+			logHandler.Error("picture not found", "err", err.Error())
+			c.JSON(http.StatusNotFound, response.Error("Picture not found"))
+			return
+		}
+		if errors.Is(err, pictureManager.ErrInvalidWidth) {
+			logHandler.Error("invalid thumbnail width", "err", err.Error())
+			c.JSON(http.StatusBadRequest, response.Error(err.Error()))
+			return
+		}
+		logHandler.Error("failed to get picture", "err", err.Error())
+		c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+		return
+	}
+	defer obj.Close()
+
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("Cache-Control", cacheControl)
+	c.Header("ETag", obj.ETag)
+
+	http.ServeContent(c.Writer, c.Request, "", obj.ModTime, obj)
+}
@@ -0,0 +1,110 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/broker"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type Exchanger interface {
+	WriteUpdate(ctx context.Context, productID int64, patch *models.ProductPatch) (*models.Product, error)
+}
+
+// New handles PUT with the whole product and PATCH with the changed fields only.
+func New(log *slog.Logger, exchanger Exchanger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		logHandler := log.With("requestID", requestid.Get(c))
+
+		idParam := c.Param("id")
+
+		productID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil || productID < 1 {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "id", "query", idParam)
+
+			c.JSON(http.StatusBadRequest, response.Error("product ID is not integer"))
+			return
+		}
+
+		var patch *models.ProductPatch
+
+		if c.Request.Method == http.MethodPut {
+			var product models.Product
+
+			if err := c.BindJSON(&product); err != nil {
+				logHandler.Error(types.ErrDecodeReqBody.Error(), "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error(types.ErrDecodeReqBody.Error()))
+				return
+			}
+
+			if err := validator.New().Struct(product); err != nil {
+				validatorErr := err.(validator.ValidationErrors)
+
+				logHandler.Error("invalid request", "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.ValidationError(validatorErr))
+				return
+			}
+
+			patch = product.Patch()
+		} else {
+			// a null body binds to an empty patch, binding to a nil pointer panics in gin
+			patch = &models.ProductPatch{}
+
+			if err := c.BindJSON(patch); err != nil {
+				logHandler.Error(types.ErrDecodeReqBody.Error(), "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error(types.ErrDecodeReqBody.Error()))
+				return
+			}
+
+			if err := validator.New().Struct(patch); err != nil {
+				validatorErr := err.(validator.ValidationErrors)
+
+				logHandler.Error("invalid request", "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.ValidationError(validatorErr))
+				return
+			}
+		}
+
+		product, err := exchanger.WriteUpdate(c.Request.Context(), productID, patch)
+		if err != nil {
+			if errors.Is(err, storage.ErrProductIDnotFound) {
+				logHandler.Error("product not found", "productID", productID)
+
+				c.JSON(http.StatusNotFound, response.Error("product not found"))
+				return
+			}
+
+			if errors.Is(err, broker.ErrUnknownPicture) {
+				logHandler.Error("unknown picture", "err", err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error(err.Error()))
+				return
+			}
+
+			logHandler.Error("failed to write update to broker", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		logHandler.Info("product update added to queue", "productID", productID)
+
+		c.JSON(http.StatusOK, response.OKWithPayload(product))
+	}
+}
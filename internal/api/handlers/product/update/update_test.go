@@ -0,0 +1,137 @@
+package update_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"prodLoaderREST/internal/api/handlers/product/update"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exchanger keeps the last patch, products other than 1 are not found.
+type exchanger struct {
+	patch *models.ProductPatch
+}
+
+func (e *exchanger) WriteUpdate(ctx context.Context, productID int64, patch *models.ProductPatch) (*models.Product, error) {
+	if productID != 1 {
+		return nil, storage.ErrProductIDnotFound
+	}
+
+	e.patch = patch
+
+	product := &models.Product{Id: productID}
+	patch.Apply(product)
+
+	return product, nil
+}
+
+func patch(t *testing.T, id string, body string) (*httptest.ResponseRecorder, *exchanger) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	ex := &exchanger{}
+
+	router := gin.New()
+	router.PATCH("/products/:id", update.New(slog.New(slog.NewTextHandler(io.Discard, nil)), ex))
+
+	req := httptest.NewRequest(http.MethodPatch, "/products/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec, ex
+}
+
+func TestPatch(t *testing.T) {
+	rec, ex := patch(t, "1", `{"title":"кеды","picturesURL":[],"vk":{"toLoad":false}}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+
+	if ex.patch == nil || *ex.patch.Title != "кеды" || *ex.patch.VK.ToLoad {
+		t.Fatalf("unexpected patch: %+v", ex.patch)
+	}
+
+	// the fields that are not sent are left as they are
+	if ex.patch.Price != nil || ex.patch.MainPictureURL != nil || ex.patch.Ucoz != nil {
+		t.Fatalf("fields not sent are patched: %+v", ex.patch)
+	}
+
+	// an empty list removes the pictures
+	if ex.patch.PicturesURL == nil || len(*ex.patch.PicturesURL) != 0 {
+		t.Fatalf("expected the pictures cleared, got %v", ex.patch.PicturesURL)
+	}
+}
+
+func TestPatchValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"empty title", `{"title":""}`, "Title"},
+		{"zero price", `{"price":0}`, "Price"},
+		{"empty main picture", `{"mainPictureURL":""}`, "MainPictureURL"},
+		{"empty picture", `{"picturesURL":["http://pics/1",""]}`, "PicturesURL[1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, ex := patch(t, "1", tt.body)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d %s", rec.Code, rec.Body)
+			}
+
+			var resp response.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(resp.Error, "field "+tt.field+" ") {
+				t.Fatalf("expected field %s refused, got %q", tt.field, resp.Error)
+			}
+
+			if ex.patch != nil {
+				t.Fatal("invalid patch is written")
+			}
+		})
+	}
+}
+
+func TestPatchStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		body string
+		code int
+	}{
+		{"bad ID", "x", `{"title":"кеды"}`, http.StatusBadRequest},
+		{"not JSON", "1", `title`, http.StatusBadRequest},
+		{"null is an empty patch", "1", `null`, http.StatusOK},
+		{"not found", "2", `{"title":"кеды"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := patch(t, tt.id, tt.body)
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d %s", tt.code, rec.Code, rec.Body)
+			}
+		})
+	}
+}
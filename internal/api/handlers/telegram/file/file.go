@@ -0,0 +1,54 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/services/telegram"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FileOpener interface {
+	OpenFile(ctx context.Context, fileID string) (io.ReadCloser, string, error)
+}
+
+// New serves a picture sent to the bot by its file ID, the marketplaces
+// download the pictures of products added from Telegram here.
+func New(log *slog.Logger, opener FileOpener) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		fileID := strings.TrimSuffix(c.Param("file"), ".jpg")
+
+		body, contentType, err := opener.OpenFile(c.Request.Context(), fileID)
+		if err != nil {
+			if errors.Is(err, telegram.ErrFileNotFound) {
+				logHandler.Error("telegram file not found", "fileID", fileID)
+
+				c.JSON(http.StatusNotFound, response.Error("file not found"))
+				return
+			}
+
+			logHandler.Error("failed to get telegram file", "err", err.Error())
+
+			c.JSON(http.StatusBadGateway, response.Error("failed to get file"))
+			return
+		}
+		defer body.Close()
+
+		// the file server of the Bot API doesn't always know the type
+		if !strings.HasPrefix(contentType, "image/") {
+			contentType = "image/jpeg"
+		}
+
+		c.DataFromReader(http.StatusOK, -1, contentType, body, nil)
+	}
+}
@@ -0,0 +1,38 @@
+package market
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Importer interface {
+	ImportMarket(ctx context.Context, async bool) (*models.ImportBatch, error)
+}
+
+// New imports the vk market items that are not linked to products. The items
+// are imported in the background, the batch is available at /products/import/:id.
+func New(log *slog.Logger, importer Importer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		batch, err := importer.ImportMarket(c.Request.Context(), true)
+		if err != nil {
+			logHandler.Error("failed to import vk market", "err", err.Error())
+
+			c.JSON(http.StatusBadGateway, response.Error("failed to import vk market"))
+			return
+		}
+
+		logHandler.Info("vk market import started", "batchID", batch.ID, "items", batch.Total)
+
+		c.JSON(http.StatusAccepted, response.OKWithPayload(batch))
+	}
+}
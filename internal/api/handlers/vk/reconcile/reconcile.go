@@ -0,0 +1,47 @@
+package reconcile
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Reconciler interface {
+	Reconcile(ctx context.Context, repair bool) (*models.ReconcileReport, error)
+}
+
+// New compares storage with the vk market right away. Only the report is
+// returned unless repair=true, then the drift is fixed as configured.
+func New(log *slog.Logger, reconciler Reconciler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		repairQuery := c.DefaultQuery("repair", "false")
+
+		repair, err := strconv.ParseBool(repairQuery)
+		if err != nil {
+			logHandler.Error("invalid repair parameter", "query", repairQuery)
+
+			c.JSON(http.StatusBadRequest, response.Error("repair is not boolean"))
+			return
+		}
+
+		report, err := reconciler.Reconcile(c.Request.Context(), repair)
+		if err != nil {
+			logHandler.Error("failed to reconcile vk market", "err", err.Error())
+
+			c.JSON(http.StatusBadGateway, response.Error("failed to reconcile vk market"))
+			return
+		}
+
+		c.JSON(http.StatusOK, response.OKWithPayload(report))
+	}
+}
@@ -0,0 +1,93 @@
+package deliveries
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/api/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeliveriesLister interface {
+	WebhookDeliveries(ctx context.Context, status string, offset int, limit int) (deliveries []*models.WebhookDelivery, count int, err error)
+}
+
+const defaultPage = "1"
+const defaultLimit = "10"
+
+// New returns the webhook delivery log, the latest first, ?status=failed
+// lists only the deliveries with the status.
+func New(log *slog.Logger, lister DeliveriesLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		var pag types.Pagination
+
+		var err error
+
+		pageQuery := c.DefaultQuery("page", defaultPage)
+
+		pag.Page, err = strconv.Atoi(pageQuery)
+		if err != nil || pag.Page < 1 {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "page", "query", pageQuery)
+
+			c.JSON(http.StatusBadRequest, response.Error(fmt.Sprintf("Invalid parameter:%s", pageQuery)))
+
+			return
+		}
+
+		limitQuery := c.DefaultQuery("limit", defaultLimit)
+
+		pag.Limit, err = strconv.Atoi(limitQuery)
+		if err != nil || pag.Limit < 1 {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "limit", "query", limitQuery)
+
+			c.JSON(http.StatusBadRequest, response.Error(fmt.Sprintf("Invalid parameter:%s", limitQuery)))
+
+			return
+		}
+
+		status := c.Query("status")
+
+		switch status {
+		case "", models.WebhookStatusPending, models.WebhookStatusDelivered, models.WebhookStatusFailed:
+		default:
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "status", "query", status)
+
+			c.JSON(http.StatusBadRequest, response.Error(fmt.Sprintf("Invalid parameter:%s", status)))
+
+			return
+		}
+
+		deliveries, count, err := lister.WebhookDeliveries(ctx, status, pag.Offset(), pag.Limit)
+		if err != nil {
+			logHandler.Error("can't get list of webhook deliveries", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Server Error"))
+			return
+		}
+
+		if deliveries == nil {
+			deliveries = []*models.WebhookDelivery{}
+		}
+
+		meta := &types.Meta{
+			Total:  count,
+			Limit:  pag.Limit,
+			Offset: pag.Offset(),
+			Next:   (pag.Offset() + pag.Limit) < count,
+		}
+
+		c.JSON(http.StatusOK, response.OKWithPayload(map[string]interface{}{"data": deliveries, "meta": meta}))
+	}
+}
@@ -0,0 +1,57 @@
+package redeliver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"prodLoaderREST/internal/api/middlewares/requestid"
+	"prodLoaderREST/internal/api/types"
+	"prodLoaderREST/internal/lib/api/response"
+	"prodLoaderREST/internal/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookRedeliverer interface {
+	Redeliver(ctx context.Context, deliveryID int64) error
+}
+
+// New sends a failed webhook delivery again.
+func New(log *slog.Logger, redeliverer WebhookRedeliverer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logHandler := log.With(
+			slog.String("requestID", requestid.Get(c)),
+		)
+
+		idParam := c.Param("id")
+
+		deliveryID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			logHandler.Error(types.ErrConvertParam.Error(), "param", "id", "query", idParam)
+
+			c.JSON(http.StatusBadRequest, response.Error("delivery ID is not integer"))
+			return
+		}
+
+		err = redeliverer.Redeliver(c.Request.Context(), deliveryID)
+		if err != nil {
+			if errors.Is(err, storage.ErrWebhookDeliveryNotFound) {
+				logHandler.Error("failed delivery not found", "deliveryID", deliveryID)
+
+				c.JSON(http.StatusNotFound, response.Error("failed delivery not found"))
+				return
+			}
+
+			logHandler.Error("failed to redeliver webhook", "err", err.Error())
+
+			c.JSON(http.StatusInternalServerError, response.Error("Internal Error"))
+			return
+		}
+
+		logHandler.Info("webhook redelivered", "deliveryID", deliveryID)
+
+		c.JSON(http.StatusOK, response.OK())
+	}
+}
@@ -0,0 +1,18 @@
+package types
+
+import "prodLoaderREST/internal/domain/models"
+
+// ProductJobs are the jobs of a product, changes return the jobs they
+// enqueued so they can be tracked at /jobs/:id.
+type ProductJobs struct {
+	ProductID int64         `json:"productID"`
+	Jobs      []*models.Job `json:"jobs"`
+}
+
+func NewProductJobs(productID int64, jobs []*models.Job) ProductJobs {
+	if jobs == nil {
+		jobs = make([]*models.Job, 0)
+	}
+
+	return ProductJobs{ProductID: productID, Jobs: jobs}
+}
@@ -2,101 +2,484 @@ package broker
 
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"prodLoaderREST/internal/domain/models"
 	"prodLoaderREST/internal/storage"
 	"prodLoaderREST/internal/storage/pictureManager"
+	"slices"
+	"sync"
+	"time"
 )
 
-var VKaddProductChannel = make(chan *models.Product, 100)
-var VKdeleteProductChannel = make(chan *VkToDelete, 100)
+const queueSize = 100
 
-var UcozAddProductChannel = make(chan *models.Product, 100)
-var UcozDeleteProductChannel = make(chan *models.Product, 100)
+// allPlatforms are the platforms a product is published to, in the order its jobs are enqueued.
+var allPlatforms = []string{models.PlatformVK, models.PlatformUcoz, models.PlatformAvito}
+
+type Options struct {
+	PollInterval time.Duration
+	LeaseTimeout time.Duration
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+}
+
+// Validate checks the options read from the environment, a zero poll interval
+// panics the ticker of the dispatcher.
+func (o Options) Validate() error {
+	if o.PollInterval <= 0 {
+		return fmt.Errorf("job poll interval must be positive")
+	}
+
+	// the lease is stored with a precision of a second
+	if o.LeaseTimeout < time.Second {
+		return fmt.Errorf("job lease timeout must be at least a second")
+	}
+
+	if o.MaxAttempts <= 0 {
+		return fmt.Errorf("job max attempts must be positive")
+	}
+
+	if o.BackoffBase <= 0 || o.BackoffMax < o.BackoffBase {
+		return fmt.Errorf("job backoff must be positive and its max not less than its base")
+	}
+
+	return nil
+}
+
+// Notifier is told about the outcome of every job: published, unpublished or failed.
+type Notifier interface {
+	Notify(ctx context.Context, event *models.WebhookEvent)
+}
+
+// Pictures keeps the pictures of products, they are stored when a product is
+// written and removed when it's deleted. PictureURL returns the URL of an
+// uploaded picture, pictureManager.ErrNotFound for an unknown ID.
+type Pictures interface {
+	SavePictures(ctx context.Context, productID int64, urls []string) error
+	DeletePictures(ctx context.Context, productID int64) error
+	PictureURL(ctx context.Context, id string) (string, error)
+}
 
 type Exchanger struct {
-	log     *slog.Logger
-	storage storage.Storage
+	log      *slog.Logger
+	storage  storage.Storage
+	notifier Notifier
+	pictures Pictures
+	opts     Options
+	wakeup   chan struct{}
+
+	mu     sync.RWMutex
+	queues map[string]chan *Job
 }
 
-func New(log *slog.Logger, storage storage.Storage) *Exchanger {
+func New(log *slog.Logger, storage storage.Storage, notifier Notifier, pictures Pictures, opts Options) *Exchanger {
 	return &Exchanger{
-		log:     log,
-		storage: storage,
+		log:      log,
+		storage:  storage,
+		notifier: notifier,
+		pictures: pictures,
+		opts:     opts,
+		wakeup:   make(chan struct{}, 1),
+		queues:   make(map[string]chan *Job),
+	}
+}
+
+// Subscribe returns the queue of jobs of the platform. Jobs of platforms
+// nobody subscribed to are kept in the outbox until a subscriber appears.
+// Size is how many jobs wait in the queue, queueSize when it's not positive.
+func (e *Exchanger) Subscribe(platform string, size int) <-chan *Job {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if size <= 0 {
+		size = queueSize
+	}
+
+	queue, ok := e.queues[platform]
+	if !ok {
+		queue = make(chan *Job, size)
+		e.queues[platform] = queue
+	}
+
+	e.notify()
+
+	return queue
+}
+
+func (e *Exchanger) queue(platform string) (chan *Job, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	queue, ok := e.queues[platform]
+
+	return queue, ok
+}
+
+func (e *Exchanger) platforms() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	platforms := make([]string, 0, len(e.queues))
+	for platform := range e.queues {
+		platforms = append(platforms, platform)
 	}
+
+	return platforms
 }
 
-func (e *Exchanger) WriteAdd(ctx context.Context, product *models.Product) error {
+// WriteAdd saves the product with an add job for every platform it's loaded to
+// and returns the jobs.
+func (e *Exchanger) WriteAdd(ctx context.Context, product *models.Product) ([]*models.Job, error) {
 
 	if product == nil {
-		return fmt.Errorf("nil product")
+		return nil, fmt.Errorf("nil product")
 	}
 
-	id, err := e.storage.Save(ctx, product)
+	patch := product.Patch()
+
+	if err := e.resolvePictures(ctx, patch); err != nil {
+		return nil, err
+	}
+
+	patch.Apply(product)
+
+	var jobs []*models.Job
+
+	if product.VK.ToLoad {
+		job, err := newJob(models.PlatformVK, models.JobActionAdd, product)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if product.Ucoz.ToLoad {
+		job, err := newJob(models.PlatformUcoz, models.JobActionAdd, product)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if product.Avito.ToLoad {
+		job, err := newJob(models.PlatformAvito, models.JobActionAdd, product)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	id, err := e.storage.Save(ctx, product, jobs...)
 	if err != nil {
 
-		return err
+		return nil, err
 	}
 
 	product.Id = id
 
-	err = pictureManager.SavePicture(int(id), product.MainPictureURL)
+	err = e.pictures.SavePictures(ctx, id, product.Pictures())
 	if err != nil {
-		e.log.Warn("failed to save picture", "err", err.Error())
+		e.log.Warn("failed to save pictures", "err", err.Error())
+	}
+
+	e.notify()
+
+	return jobs, nil
+}
+
+// WriteUpdate applies the patch to the stored product and enqueues an update
+// job for every platform the product is published to. A platform turned on by
+// the patch gets an add job, a published one turned off gets a delete job.
+func (e *Exchanger) WriteUpdate(ctx context.Context, productID int64, patch *models.ProductPatch) (*models.Product, error) {
+	if patch == nil {
+		return nil, fmt.Errorf("nil patch")
+	}
+
+	product, err := e.storage.Product(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldPictures := product.Pictures()
+
+	if err := e.resolvePictures(ctx, patch); err != nil {
+		return nil, err
+	}
+
+	before := *product
+
+	patch.Apply(product)
+
+	platforms, err := e.publishedPlatforms(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*models.Job
+
+	for _, platform := range allPlatforms {
+		published := slices.Contains(platforms, platform)
+
+		var job *models.Job
+
+		switch {
+		case published && product.ToLoad(platform):
+			job, err = newJob(platform, models.JobActionUpdate, product)
+		case published && before.ToLoad(platform):
+			// turned off: the item is removed, the product is kept
+			job, err = newJob(platform, models.JobActionDelete, &ToDelete{
+				ProductID:         int(productID),
+				PlatformProductID: platformProductID(&before, platform),
+			})
+		case !published && product.ToLoad(platform) && !before.ToLoad(platform):
+			job, err = newJob(platform, models.JobActionAdd, product)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err := e.storage.Update(ctx, product, jobs...); err != nil {
+		return nil, err
+	}
+
+	if !slices.Equal(product.Pictures(), oldPictures) {
+		err = e.pictures.SavePictures(ctx, productID, product.Pictures())
+		if err != nil {
+			e.log.Warn("failed to save pictures", "err", err.Error())
+		}
 	}
 
-	go func() {
-		if product.VK.ToLoad {
-			VKaddProductChannel <- product
+	e.log.Debug("product update written", "productID", productID, "platforms", platforms)
+
+	e.notify()
+
+	return product, nil
+}
+
+// resolvePictures replaces the IDs of uploaded pictures in the patch with
+// their URLs, picture IDs are appended to the picture URLs.
+func (e *Exchanger) resolvePictures(ctx context.Context, patch *models.ProductPatch) error {
+	if patch.MainPictureID != nil && *patch.MainPictureID != "" {
+		url, err := e.pictureURL(ctx, *patch.MainPictureID)
+		if err != nil {
+			return err
 		}
-	}()
 
-	go func() {
-		if product.Ucoz.ToLoad {
-			UcozAddProductChannel <- product
+		*patch.MainPictureID = ""
+		patch.MainPictureURL = &url
+	}
+
+	if patch.PictureIDs != nil && len(*patch.PictureIDs) > 0 {
+		var urls []string
+		if patch.PicturesURL != nil {
+			urls = slices.Clone(*patch.PicturesURL)
 		}
-	}()
 
-	// go func() {
-	// 	if product.Avito.ToLoad {
+		for _, id := range *patch.PictureIDs {
+			url, err := e.pictureURL(ctx, id)
+			if err != nil {
+				return err
+			}
 
-	// 	}
-	// }()
+			urls = append(urls, url)
+		}
+
+		*patch.PictureIDs = nil
+		patch.PicturesURL = &urls
+	}
 
 	return nil
 }
 
-func (e *Exchanger) WriteDelete(productID int) error {
+func (e *Exchanger) pictureURL(ctx context.Context, id string) (string, error) {
+	url, err := e.pictures.PictureURL(ctx, id)
+	if errors.Is(err, pictureManager.ErrNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPicture, id)
+	}
+
+	return url, err
+}
+
+// publishedPlatforms returns the platforms the product is published to.
+func (e *Exchanger) publishedPlatforms(ctx context.Context, productID int64) ([]string, error) {
+	var platforms []string
+
+	vkProductID, err := e.storage.VkProductID(productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vk prod id:%w", err)
+	}
 
-	DeleteID := VkToDelete{
-		ProductID: productID,
+	if vkProductID != 0 {
+		platforms = append(platforms, models.PlatformVK)
 	}
 
-	if DeleteID.ProductID < 1 {
-		return fmt.Errorf("ProductID can't be less 1")
+	ucozProductID, err := e.storage.UcozProductID(productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ucoz prod id:%w", err)
 	}
 
-	var err error
+	if ucozProductID != 0 {
+		platforms = append(platforms, models.PlatformUcoz)
+	}
 
-	DeleteID.VkProductID, err = e.storage.VkProductID(int64(DeleteID.ProductID))
+	inAvitoFeed, err := e.storage.InAvitoFeed(ctx, productID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("product is not exists")
+		return nil, fmt.Errorf("failed to check avito feed:%w", err)
+	}
+
+	if inAvitoFeed {
+		platforms = append(platforms, models.PlatformAvito)
+	}
+
+	return platforms, nil
+}
+
+// WriteDelete enqueues a delete job for every platform the product is
+// published to and returns the jobs. Without platforms the product is deleted
+// and its stored picture is removed, otherwise it's only unpublished from them.
+func (e *Exchanger) WriteDelete(ctx context.Context, productID int64, platforms []string) ([]*models.Job, error) {
+
+	if productID < 1 {
+		return nil, fmt.Errorf("ProductID can't be less 1")
+	}
+
+	for _, platform := range platforms {
+		if !slices.Contains(allPlatforms, platform) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPlatform, platform)
 		}
-		return fmt.Errorf("failed to get vk prod id:%w", err)
+	}
+
+	product, err := e.storage.Product(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
 
+	// an ad stays in the feed until Avito reports its ID back
+	inAvitoFeed, err := e.storage.InAvitoFeed(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check avito feed:%w", err)
 	}
 
-	go func() {
-		VKdeleteProductChannel <- &DeleteID
-	}()
-	e.log.Debug("productID written to delete VK", "VKproductID", DeleteID.VkProductID)
+	var jobs []*models.Job
+
+	for _, platform := range allPlatforms {
+		if len(platforms) > 0 && !slices.Contains(platforms, platform) {
+			continue
+		}
+
+		platformProductID := platformProductID(product, platform)
 
-	// тут также потом будет писать в юкоз
+		if platformProductID == 0 && (platform != models.PlatformAvito || !inAvitoFeed) {
+			continue
+		}
+
+		job, err := newJob(platform, models.JobActionDelete, &ToDelete{
+			ProductID:         int(productID),
+			PlatformProductID: platformProductID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+
+		e.log.Debug("productID written to delete", "platform", platform, "platformProductID", platformProductID)
+	}
+
+	if len(platforms) > 0 {
+		err = e.storage.Unpublish(ctx, productID, platforms, jobs...)
+	} else {
+		err = e.storage.Delete(ctx, productID, jobs...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(platforms) == 0 {
+		err = e.pictures.DeletePictures(ctx, productID)
+		if err != nil {
+			e.log.Warn("failed to delete pictures", "err", err.Error())
+		}
+	}
+
+	e.notify()
+
+	return jobs, nil
+
+}
+
+// WriteJob queues an add or update of the stored product on one platform.
+func (e *Exchanger) WriteJob(ctx context.Context, productID int64, platform string, action string) error {
+	if action != models.JobActionAdd && action != models.JobActionUpdate {
+		return fmt.Errorf("unsupported job action: %s", action)
+	}
+
+	job, err := newJob(platform, action, nil)
+	if err != nil {
+		return err
+	}
+
+	job.ProductID = productID
+
+	if _, err := e.storage.EnqueueJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to enqueue %s job: %w", action, err)
+	}
+
+	e.notify()
+
+	return nil
+}
+
+// Redrive returns a job from the dead-letter list to the queue.
+func (e *Exchanger) Redrive(ctx context.Context, jobID int64) error {
+	if err := e.storage.RedriveJob(ctx, jobID); err != nil {
+		return err
+	}
+
+	e.notify()
 
 	return nil
+}
 
+// platformProductID is the ID of the product on the platform, 0 when it's not published.
+func platformProductID(product *models.Product, platform string) int {
+	switch platform {
+	case models.PlatformVK:
+		return product.VK.ProductID
+	case models.PlatformUcoz:
+		return product.Ucoz.ProductID
+	case models.PlatformAvito:
+		return product.Avito.ProductID
+	}
+
+	return 0
+}
+
+func newJob(platform string, action string, payload any) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	return &models.Job{
+		Platform: platform,
+		Action:   action,
+		Payload:  string(data),
+	}, nil
+}
+
+// notify wakes the dispatcher up without waiting for the next poll.
+func (e *Exchanger) notify() {
+	select {
+	case e.wakeup <- struct{}{}:
+	default:
+	}
 }
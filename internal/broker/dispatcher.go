@@ -0,0 +1,303 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/storage"
+	"time"
+)
+
+const leaseBatch = 20
+
+// Run returns jobs left unfinished by a previous run to the queue and then
+// dispatches outbox jobs to the consumers until ctx is cancelled.
+func (e *Exchanger) Run(ctx context.Context) {
+	n, err := e.storage.RequeueJobs(ctx)
+	if err != nil {
+		e.log.Error("failed to requeue unfinished jobs", "err", err.Error())
+	}
+
+	if n > 0 {
+		e.log.Info("unfinished jobs replayed", "count", n)
+	}
+
+	ticker := time.NewTicker(e.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		e.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			e.log.Info("dispatcher stopped")
+			return
+		case <-ticker.C:
+		case <-e.wakeup:
+		}
+	}
+}
+
+func (e *Exchanger) dispatch(ctx context.Context) {
+	for _, platform := range e.platforms() {
+		e.dispatchPlatform(ctx, platform)
+	}
+}
+
+// dispatchPlatform leases no more jobs than the queue of the platform has
+// room for, a job waiting in a full queue could outlive its lease and be
+// handled twice.
+func (e *Exchanger) dispatchPlatform(ctx context.Context, platform string) {
+	ch, ok := e.queue(platform)
+	if !ok {
+		return
+	}
+
+	for {
+		limit := min(leaseBatch, cap(ch)-len(ch))
+		if limit == 0 {
+			return
+		}
+
+		records, err := e.storage.LeaseJobs(ctx, []string{platform}, limit, e.opts.LeaseTimeout)
+		if err != nil {
+			e.log.Error("failed to lease jobs", "platform", platform, "err", err.Error())
+			return
+		}
+
+		for _, record := range records {
+			e.route(ctx, record)
+		}
+
+		if len(records) < limit {
+			return
+		}
+	}
+}
+
+func (e *Exchanger) route(ctx context.Context, record *models.Job) {
+	log := e.log.With("jobID", record.ID, "platform", record.Platform, "action", record.Action)
+
+	ch, ok := e.queue(record.Platform)
+	if !ok {
+		log.Error("no consumer for job")
+		e.finish(ctx, record, Permanent(fmt.Errorf("no consumer for %s", record.Platform)))
+		return
+	}
+
+	job, err := e.decode(ctx, record)
+	if err != nil {
+		log.Error("failed to decode job", "err", err.Error())
+		e.finish(ctx, record, err)
+		return
+	}
+
+	select {
+	case ch <- job:
+		log.Debug("job dispatched", "attempt", record.Attempts)
+	default:
+		// the job stays leased and will be picked up again once the lease expires
+		log.Warn("consumer queue is full")
+	}
+}
+
+func (e *Exchanger) decode(ctx context.Context, record *models.Job) (*Job, error) {
+	job := &Job{
+		ID:        record.ID,
+		ProductID: record.ProductID,
+		Platform:  record.Platform,
+		Action:    record.Action,
+		Attempt:   record.Attempts,
+		done: func(err error) {
+			// the result is saved even if the dispatcher is already stopping
+			e.finish(context.WithoutCancel(ctx), record, err)
+
+			// the queue has room for the next job
+			e.notify()
+		},
+		stage: func(stage string) {
+			if err := e.storage.SetJobStage(context.WithoutCancel(ctx), record.ID, record.Attempts, stage, e.opts.LeaseTimeout); err != nil {
+				e.log.Warn("failed to save job stage", "jobID", record.ID, "stage", stage, "err", err.Error())
+			}
+		},
+	}
+
+	switch record.Action {
+	case models.JobActionAdd, models.JobActionUpdate:
+		// the stored product is published, so a retry picks up later edits
+		product, err := e.storage.Product(ctx, record.ProductID)
+		if errors.Is(err, storage.ErrProductIDnotFound) {
+			return nil, Permanent(fmt.Errorf("failed to get product: %w", err))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get product: %w", err)
+		}
+		job.Product = product
+	case models.JobActionDelete:
+		job.Delete = &ToDelete{}
+		if err := json.Unmarshal([]byte(record.Payload), job.Delete); err != nil {
+			return nil, Permanent(fmt.Errorf("invalid delete payload: %w", err))
+		}
+	default:
+		return nil, Permanent(fmt.Errorf("unknown job action: %s", record.Action))
+	}
+
+	return job, nil
+}
+
+// finish saves the result of the job: done, retry after a backoff, or the
+// dead-letter list once the error is permanent or attempts are exhausted.
+func (e *Exchanger) finish(ctx context.Context, record *models.Job, jobErr error) {
+	log := e.log.With("jobID", record.ID, "platform", record.Platform, "action", record.Action, "attempt", record.Attempts)
+
+	var (
+		err   error
+		event string
+	)
+
+	switch {
+	case jobErr == nil:
+		err = e.storage.CompleteJob(ctx, record.ID, record.Attempts)
+		event = models.WebhookEventPublished
+		if record.Action == models.JobActionDelete {
+			event = models.WebhookEventUnpublished
+		}
+	case errors.Is(jobErr, ErrPermanent) || record.Attempts >= e.opts.MaxAttempts:
+		log.Error("job moved to dead-letter list", "err", jobErr.Error())
+		err = e.storage.BuryJob(ctx, record.ID, record.Attempts, jobErr.Error())
+		event = models.WebhookEventFailed
+	default:
+		delay := e.backoff(record.Attempts)
+		log.Warn("job failed, will retry", "err", jobErr.Error(), "delay", delay)
+		err = e.storage.RetryJob(ctx, record.ID, record.Attempts, jobErr.Error(), delay)
+	}
+
+	if errors.Is(err, storage.ErrJobLeaseLost) {
+		// the lease expired while the job was handled, the job is leased again
+		// and its result belongs to the new holder
+		log.Warn("job result dropped, the lease is lost")
+		return
+	}
+
+	if err != nil {
+		log.Error("failed to save job result", "err", err.Error())
+		return
+	}
+
+	if event != "" && e.notifier != nil {
+		e.notifier.Notify(ctx, e.event(ctx, record, event, jobErr))
+	}
+
+	if jobErr == nil && record.Action == models.JobActionAdd {
+		e.withdraw(ctx, record)
+	}
+}
+
+// withdraw deletes the product just published from the platform if it was
+// deleted or unpublished while the add was in flight: the delete saw no ID on
+// the platform and queued nothing there.
+func (e *Exchanger) withdraw(ctx context.Context, record *models.Job) {
+	log := e.log.With("jobID", record.ID, "productID", record.ProductID, "platform", record.Platform)
+
+	platformProductID, withdrawn, err := e.storage.Withdrawn(ctx, record.ProductID, record.Platform)
+	if err != nil {
+		log.Error("failed to check withdrawn product", "err", err.Error())
+		return
+	}
+
+	if !withdrawn {
+		return
+	}
+
+	// an ad stays in the feed until Avito reports its ID back
+	if platformProductID == 0 && record.Platform != models.PlatformAvito {
+		return
+	}
+
+	job, err := newJob(record.Platform, models.JobActionDelete, &ToDelete{
+		ProductID:         int(record.ProductID),
+		PlatformProductID: platformProductID,
+	})
+	if err != nil {
+		log.Error("failed to create delete job", "err", err.Error())
+		return
+	}
+
+	job.ProductID = record.ProductID
+
+	enqueued, err := e.storage.EnqueueWithdraw(ctx, job)
+	if err != nil {
+		log.Error("failed to enqueue delete of withdrawn product", "err", err.Error())
+		return
+	}
+
+	if enqueued {
+		log.Info("product published while withdrawn, delete enqueued", "deleteJobID", job.ID)
+		e.notify()
+	}
+}
+
+// event describes the outcome of the job for the notifier, the product is
+// looked up for its title and ID on the platform.
+func (e *Exchanger) event(ctx context.Context, record *models.Job, name string, jobErr error) *models.WebhookEvent {
+	event := &models.WebhookEvent{
+		Event:      name,
+		JobID:      record.ID,
+		ProductID:  record.ProductID,
+		Platform:   record.Platform,
+		Action:     record.Action,
+		Attempts:   record.Attempts,
+		OccurredAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if jobErr != nil {
+		event.Error = jobErr.Error()
+	}
+
+	// a deleted product is not found, the event is sent without its title
+	product, err := e.storage.Product(ctx, record.ProductID)
+	if err == nil {
+		event.Title = product.Title
+	}
+
+	// the ID is cleared by the delete, it's kept in the payload of the job
+	if record.Action == models.JobActionDelete {
+		var payload ToDelete
+		if err := json.Unmarshal([]byte(record.Payload), &payload); err == nil {
+			event.PlatformProductID = payload.PlatformProductID
+		}
+
+		return event
+	}
+
+	if product == nil {
+		return event
+	}
+
+	switch record.Platform {
+	case models.PlatformVK:
+		event.PlatformProductID = product.VK.ProductID
+	case models.PlatformUcoz:
+		event.PlatformProductID = product.Ucoz.ProductID
+	case models.PlatformAvito:
+		event.PlatformProductID = product.Avito.ProductID
+	}
+
+	return event
+}
+
+// backoff doubles the delay with every attempt, up to BackoffMax.
+func (e *Exchanger) backoff(attempt int) time.Duration {
+	delay := e.opts.BackoffBase
+
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= e.opts.BackoffMax {
+			return e.opts.BackoffMax
+		}
+	}
+
+	return delay
+}
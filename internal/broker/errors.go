@@ -0,0 +1,31 @@
+package broker
+
+import "errors"
+
+var (
+	ErrPermanent       = errors.New("permanent error")
+	ErrUnknownPlatform = errors.New("unknown platform")
+	ErrUnknownPicture  = errors.New("unknown picture")
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() []error {
+	return []error{e.err, ErrPermanent}
+}
+
+// Permanent marks err as not worth retrying: the job goes straight to the
+// dead-letter list.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
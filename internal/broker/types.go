@@ -1,6 +1,56 @@
 package broker
 
-type VkToDelete struct {
-	ProductID   int
-	VkProductID int
+import (
+	"context"
+	"prodLoaderREST/internal/domain/models"
+)
+
+// ToDelete is the payload of a delete job: the local product and its ID on the platform.
+type ToDelete struct {
+	ProductID         int
+	PlatformProductID int
+}
+
+// Job is an outbox record leased by the dispatcher and handed to a consumer.
+// The consumer must call Done exactly once when it is finished with the job.
+type Job struct {
+	ID        int64
+	ProductID int64
+	Platform  string
+	Action    string
+	Attempt   int
+
+	Product *models.Product
+	Delete  *ToDelete
+
+	done  func(err error)
+	stage func(stage string)
+}
+
+func (j *Job) Done(err error) {
+	if j.done != nil {
+		j.done(err)
+	}
+}
+
+// Stage saves the progress of the job, like uploading pictures.
+func (j *Job) Stage(stage string) {
+	if j.stage != nil {
+		j.stage(stage)
+	}
+}
+
+type jobKey struct{}
+
+// WithJob returns a context carrying the job, consumers report the progress through it.
+func WithJob(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, jobKey{}, job)
+}
+
+// ReportStage saves the stage of the job carried by ctx, it does nothing
+// when the consumer is called outside of a job.
+func ReportStage(ctx context.Context, stage string) {
+	if job, ok := ctx.Value(jobKey{}).(*Job); ok {
+		job.Stage(stage)
+	}
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
@@ -11,9 +12,80 @@ type Config struct {
 	Log        string `env:"LOG_MODE" env-default:"debug"`
 	ServerHost string `env:"SRV_HOST"`
 	ServerPort string `env:"SRV_PORT" env-default:"8080"`
+	PublicURL  string `env:"PUBLIC_URL"`
 	VkToken    string `env:"VK_TOKEN"`
 	VkGroupID  int    `env:"VK_GROUP_ID"`
 	DbPath     string `env:"DB_PATH" env-default:"../../storage/db.sqlite3"`
+
+	PicturesStore       string        `env:"PICTURES_STORE" env-default:"fs"`
+	PicturesPath        string        `env:"PICTURES_PATH" env-default:"../../storage/jpg"`
+	PicturesS3Endpoint  string        `env:"PICTURES_S3_ENDPOINT"`
+	PicturesS3Region    string        `env:"PICTURES_S3_REGION" env-default:"us-east-1"`
+	PicturesS3Bucket    string        `env:"PICTURES_S3_BUCKET"`
+	PicturesS3AccessKey string        `env:"PICTURES_S3_ACCESS_KEY"`
+	PicturesS3SecretKey string        `env:"PICTURES_S3_SECRET_KEY"`
+	PicturesS3Timeout   time.Duration `env:"PICTURES_S3_TIMEOUT" env-default:"30s"`
+
+	FetchTimeout      time.Duration `env:"FETCH_TIMEOUT" env-default:"30s"`
+	FetchMaxSize      int64         `env:"FETCH_MAX_SIZE" env-default:"52428800"`
+	FetchMaxRedirects int           `env:"FETCH_MAX_REDIRECTS" env-default:"5"`
+	FetchPerHost      int           `env:"FETCH_PER_HOST" env-default:"4"`
+	FetchTrustedURLs  []string      `env:"FETCH_TRUSTED_URLS" env-separator:","`
+
+	VkRateLimit      int `env:"VK_RATE_LIMIT" env-default:"3"`
+	VkWorkers        int `env:"VK_WORKERS" env-default:"2"`
+	VkPictureWorkers int `env:"VK_PICTURE_WORKERS" env-default:"2"`
+
+	VkReconcileInterval time.Duration `env:"VK_RECONCILE_INTERVAL" env-default:"1h"`
+	VkReconcileMissing  string        `env:"VK_RECONCILE_MISSING"`
+	VkReconcilePush     bool          `env:"VK_RECONCILE_PUSH" env-default:"false"`
+	VkReconcileAdopt    bool          `env:"VK_RECONCILE_ADOPT" env-default:"false"`
+
+	UcozAPIURL         string `env:"UCOZ_API_URL"`
+	UcozConsumerKey    string `env:"UCOZ_CONSUMER_KEY"`
+	UcozConsumerSecret string `env:"UCOZ_CONSUMER_SECRET"`
+	UcozToken          string `env:"UCOZ_TOKEN"`
+	UcozTokenSecret    string `env:"UCOZ_TOKEN_SECRET"`
+
+	AvitoAPIURL         string        `env:"AVITO_API_URL" env-default:"https://api.avito.ru"`
+	AvitoClientID       string        `env:"AVITO_CLIENT_ID"`
+	AvitoClientSecret   string        `env:"AVITO_CLIENT_SECRET"`
+	AvitoReportInterval time.Duration `env:"AVITO_REPORT_INTERVAL" env-default:"1h"`
+	AvitoCategory       string        `env:"AVITO_CATEGORY" env-default:"Одежда, обувь, аксессуары"`
+	AvitoGoodsType      string        `env:"AVITO_GOODS_TYPE" env-default:"Мужская одежда"`
+	AvitoAdType         string        `env:"AVITO_AD_TYPE" env-default:"Товар приобретен на продажу"`
+	AvitoCondition      string        `env:"AVITO_CONDITION" env-default:"Новое"`
+	AvitoAddress        string        `env:"AVITO_ADDRESS"`
+	AvitoContactPhone   string        `env:"AVITO_CONTACT_PHONE"`
+
+	YmlShopName string `env:"YML_SHOP_NAME"`
+	YmlCompany  string `env:"YML_COMPANY"`
+	YmlURL      string `env:"YML_URL"`
+	YmlCurrency string `env:"YML_CURRENCY" env-default:"RUR"`
+	YmlCategory string `env:"YML_CATEGORY" env-default:"Одежда"`
+
+	JobPollInterval time.Duration `env:"JOB_POLL_INTERVAL" env-default:"5s"`
+	JobLeaseTimeout time.Duration `env:"JOB_LEASE_TIMEOUT" env-default:"5m"`
+	JobMaxAttempts  int           `env:"JOB_MAX_ATTEMPTS" env-default:"5"`
+	JobBackoffBase  time.Duration `env:"JOB_BACKOFF_BASE" env-default:"30s"`
+	JobBackoffMax   time.Duration `env:"JOB_BACKOFF_MAX" env-default:"30m"`
+
+	WebhookURLs         []string      `env:"WEBHOOK_URLS" env-separator:","`
+	WebhookSecret       string        `env:"WEBHOOK_SECRET"`
+	WebhookEvents       []string      `env:"WEBHOOK_EVENTS" env-separator:"," env-default:"published,failed,unpublished"`
+	WebhookTimeout      time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"10s"`
+	WebhookPollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" env-default:"5s"`
+	WebhookMaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"8"`
+	WebhookBackoffBase  time.Duration `env:"WEBHOOK_BACKOFF_BASE" env-default:"30s"`
+	WebhookBackoffMax   time.Duration `env:"WEBHOOK_BACKOFF_MAX" env-default:"1h"`
+
+	TelegramToken          string        `env:"TELEGRAM_TOKEN"`
+	TelegramAPIURL         string        `env:"TELEGRAM_API_URL" env-default:"https://api.telegram.org"`
+	TelegramAllowedUsers   []int64       `env:"TELEGRAM_ALLOWED_USERS" env-separator:","`
+	TelegramPollTimeout    time.Duration `env:"TELEGRAM_POLL_TIMEOUT" env-default:"30s"`
+	TelegramFilesURL       string        `env:"TELEGRAM_FILES_URL"`
+	TelegramVKCategories   []string      `env:"TELEGRAM_VK_CATEGORIES" env-separator:","`
+	TelegramUcozCategories []string      `env:"TELEGRAM_UCOZ_CATEGORIES" env-separator:","`
 }
 
 func MustRead() *Config {
@@ -1,9 +1,42 @@
 package filters
 
+import "time"
+
+const (
+	SortPrice     = "price"
+	SortCreatedAt = "created_at"
+	SortTitle     = "title"
+)
+
+// Publish states of a product on a platform: published is loaded there, pending
+// has a queued job, failed has its last job in the dead-letter list, unpublished
+// is none of them.
+const (
+	PublishStateAny         = "any"
+	PublishStatePublished   = "published"
+	PublishStatePending     = "pending"
+	PublishStateFailed      = "failed"
+	PublishStateUnpublished = "unpublished"
+)
+
+// Options of the product listing, nil fields are not filtered on.
 type Options struct {
+	Search           string
 	ProductID        *int
 	CategoryID       *int
 	IsVkPublished    *bool
 	IsAvitoPublished *bool
-	Created_at       *string
+	IsUcozPublished  *bool
+	PriceMin         *int
+	PriceMax         *int
+	CreatedFrom      *time.Time
+	CreatedTo        *time.Time
+	Status           *string
+
+	// PublishState is checked on Platform, on any platform when it is empty
+	PublishState string
+	Platform     string
+
+	Sort     string
+	SortDesc bool
 }
@@ -0,0 +1,42 @@
+package models
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatXLSX   = "xlsx"
+	ImportFormatNDJSON = "ndjson"
+	// ImportFormatVK is a batch of items imported from the vk market.
+	ImportFormatVK = "vk"
+)
+
+const (
+	ImportStatusDryRun     = "dry_run"
+	ImportStatusProcessing = "processing"
+	ImportStatusDone       = "done"
+	// ImportStatusInterrupted is a batch the service stopped adding, its rows
+	// may be added only in part.
+	ImportStatusInterrupted = "interrupted"
+)
+
+// ImportBatch is one uploaded file of products, valid rows are added one by one
+// and the batch keeps the product ID or the errors of every row.
+type ImportBatch struct {
+	ID       int64       `json:"id,omitempty"`
+	Filename string      `json:"filename"`
+	Format   string      `json:"format"`
+	Status   string      `json:"status"`
+	Total    int         `json:"total"`
+	Valid    int         `json:"valid"`
+	Imported int         `json:"imported"`
+	Failed   int         `json:"failed"`
+	Rows     []ImportRow `json:"rows"`
+
+	CreatedAt string `json:"createdAt,omitempty"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+}
+
+type ImportRow struct {
+	Row       int      `json:"row"`
+	Title     string   `json:"title,omitempty"`
+	ProductID int64    `json:"productID,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
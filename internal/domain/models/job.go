@@ -0,0 +1,51 @@
+package models
+
+const (
+	PlatformVK    = "vk"
+	PlatformUcoz  = "ucoz"
+	PlatformAvito = "avito"
+)
+
+const (
+	JobActionAdd    = "add"
+	JobActionUpdate = "update"
+	JobActionDelete = "delete"
+)
+
+const (
+	JobStatusQueued     = "queued"
+	JobStatusProcessing = "processing"
+	JobStatusDone       = "done"
+	JobStatusDead       = "dead"
+	JobStatusCancelled  = "cancelled"
+)
+
+// Stages of a job on the marketplace, consumers report the ones between
+// processing and the result.
+const (
+	JobStageQueued            = "queued"
+	JobStageProcessing        = "processing"
+	JobStageUploadingPictures = "uploading_pictures"
+	JobStagePublishing        = "publishing"
+	JobStagePublished         = "published"
+	JobStageUnpublished       = "unpublished"
+	JobStageFailed            = "failed"
+	JobStageCancelled         = "cancelled"
+)
+
+// Job is a record of the outbox: one action of one product on one platform.
+type Job struct {
+	ID        int64  `json:"id"`
+	ProductID int64  `json:"productID"`
+	Platform  string `json:"platform"`
+	Action    string `json:"action"`
+	Payload   string `json:"-"`
+	Status    string `json:"status"`
+	Stage     string `json:"stage"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError,omitempty"`
+
+	AvailableAt string `json:"availableAt,omitempty"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
@@ -8,23 +8,167 @@ type Product struct {
 	Status      string `json:"status" validate:"required"`
 	Price       int    `json:"price" validate:"required"`
 
-	MainPictureURL string   `json:"mainPictureURL" validate:"required"`
-	PicturesURL    []string `json:"picturesURL" validate:"required"`
-	VK             VK       `json:"vk"`
-	Avito          Avito    `json:"avito"`
-	Ucoz           Ucoz     `json:"ucoz"`
+	MainPictureURL string   `json:"mainPictureURL" validate:"required_without=MainPictureID"`
+	PicturesURL    []string `json:"picturesURL"`
+
+	// MainPictureID and PictureIDs reference pictures uploaded to /pictures,
+	// they are turned into URLs when the product is written, the IDs follow
+	// the URLs in the pictures.
+	MainPictureID string   `json:"mainPictureID,omitempty"`
+	PictureIDs    []string `json:"pictureIDs,omitempty"`
+
+	VK    VK    `json:"vk"`
+	Avito Avito `json:"avito"`
+	Ucoz  Ucoz  `json:"ucoz"`
+
+	// Telegram are the files of pictures sent to the bot, matched with the
+	// pictures by URL.
+	Telegram []TelegramPicture `json:"-"`
+
+	CreatedAt string `json:"createdAt,omitempty"`
 }
 
+// TelegramPicture is a picture sent to the bot, URL is its download link.
+type TelegramPicture struct {
+	FileID string
+	URL    string
+}
+
+// Pictures returns the URLs of all pictures, the main one first.
+func (p *Product) Pictures() []string {
+	return append([]string{p.MainPictureURL}, p.PicturesURL...)
+}
+
+// ToLoad is set by the client, Loaded and ProductID are the state of the
+// product on the platform, filled by storage and ignored on input.
 type Avito struct {
-	ToLoad bool `json:"toLoad"`
+	ToLoad    bool `json:"toLoad"`
+	Loaded    bool `json:"loaded"`
+	ProductID int  `json:"productID,omitempty"`
 }
 
 type Ucoz struct {
 	ToLoad     bool `json:"toLoad"`
 	CategoryID int  `json:"categoryID"`
+	Loaded     bool `json:"loaded"`
+	ProductID  int  `json:"productID,omitempty"`
 }
 
 type VK struct {
 	ToLoad     bool `json:"toLoad"`
 	CategoryID int  `json:"categoryID"`
+	Loaded     bool `json:"loaded"`
+	ProductID  int  `json:"productID,omitempty"`
+}
+
+// ProductPatch is a partial update of the product, nil fields are left unchanged.
+// Strings can't be set to empty, the product requires them.
+type ProductPatch struct {
+	Title       *string `json:"title" validate:"omitempty,min=1"`
+	Description *string `json:"description" validate:"omitempty,min=1"`
+	Size        *string `json:"size" validate:"omitempty,min=1"`
+	Status      *string `json:"status" validate:"omitempty,min=1"`
+	Price       *int    `json:"price" validate:"omitempty,gt=0"`
+
+	MainPictureURL *string   `json:"mainPictureURL" validate:"omitempty,min=1"`
+	PicturesURL    *[]string `json:"picturesURL" validate:"omitempty,dive,min=1"`
+
+	MainPictureID *string   `json:"mainPictureID"`
+	PictureIDs    *[]string `json:"pictureIDs"`
+
+	VK    *PlatformPatch `json:"vk"`
+	Avito *AvitoPatch    `json:"avito"`
+	Ucoz  *PlatformPatch `json:"ucoz"`
+}
+
+// PlatformPatch is a partial update of the product settings on VK or uCoz.
+type PlatformPatch struct {
+	ToLoad     *bool `json:"toLoad"`
+	CategoryID *int  `json:"categoryID"`
+}
+
+type AvitoPatch struct {
+	ToLoad *bool `json:"toLoad"`
+}
+
+// Patch returns a patch that replaces every editable field of the product.
+func (p *Product) Patch() *ProductPatch {
+	return &ProductPatch{
+		Title:          &p.Title,
+		Description:    &p.Description,
+		Size:           &p.Size,
+		Status:         &p.Status,
+		Price:          &p.Price,
+		MainPictureURL: &p.MainPictureURL,
+		PicturesURL:    &p.PicturesURL,
+		MainPictureID:  &p.MainPictureID,
+		PictureIDs:     &p.PictureIDs,
+		VK: &PlatformPatch{
+			ToLoad:     &p.VK.ToLoad,
+			CategoryID: &p.VK.CategoryID,
+		},
+		Avito: &AvitoPatch{
+			ToLoad: &p.Avito.ToLoad,
+		},
+		Ucoz: &PlatformPatch{
+			ToLoad:     &p.Ucoz.ToLoad,
+			CategoryID: &p.Ucoz.CategoryID,
+		},
+	}
+}
+
+func (p *ProductPatch) Apply(product *Product) {
+	if p.Title != nil {
+		product.Title = *p.Title
+	}
+	if p.Description != nil {
+		product.Description = *p.Description
+	}
+	if p.Size != nil {
+		product.Size = *p.Size
+	}
+	if p.Status != nil {
+		product.Status = *p.Status
+	}
+	if p.Price != nil {
+		product.Price = *p.Price
+	}
+	if p.MainPictureURL != nil {
+		product.MainPictureURL = *p.MainPictureURL
+	}
+	if p.PicturesURL != nil {
+		product.PicturesURL = *p.PicturesURL
+	}
+	if p.VK != nil {
+		p.VK.apply(&product.VK.ToLoad, &product.VK.CategoryID)
+	}
+	if p.Avito != nil && p.Avito.ToLoad != nil {
+		product.Avito.ToLoad = *p.Avito.ToLoad
+	}
+	if p.Ucoz != nil {
+		p.Ucoz.apply(&product.Ucoz.ToLoad, &product.Ucoz.CategoryID)
+	}
+}
+
+func (p *PlatformPatch) apply(toLoad *bool, categoryID *int) {
+	if p.ToLoad != nil {
+		*toLoad = *p.ToLoad
+	}
+	if p.CategoryID != nil {
+		*categoryID = *p.CategoryID
+	}
+}
+
+// ToLoad reports whether the product is set to be published to the platform.
+func (p *Product) ToLoad(platform string) bool {
+	switch platform {
+	case PlatformVK:
+		return p.VK.ToLoad
+	case PlatformUcoz:
+		return p.Ucoz.ToLoad
+	case PlatformAvito:
+		return p.Avito.ToLoad
+	}
+
+	return false
 }
@@ -0,0 +1,50 @@
+package models
+
+const (
+	DriftMissing = "missing"
+	DriftChanged = "changed"
+	DriftUnknown = "unknown"
+)
+
+const (
+	RepairMarkDeleted = "mark_deleted"
+	RepairRepublish   = "republish"
+	RepairPush        = "push"
+	RepairAdopt       = "adopt"
+)
+
+// PlatformLink is the state of a product on a platform as it is saved in storage.
+// Deleted products are linked until their delete job is done. Name is the
+// name the product was last published with, empty when the platform doesn't
+// keep it or it's not known.
+type PlatformLink struct {
+	ProductID         int64
+	PlatformProductID int
+	Loaded            bool
+	Deleted           bool
+	Name              string
+}
+
+// ReconcileReport is the difference between storage and the items on the platform.
+type ReconcileReport struct {
+	Platform  string   `json:"platform"`
+	Repair    bool     `json:"repair"`
+	Local     int      `json:"local"`
+	Remote    int      `json:"remote"`
+	Drifts    []Drift  `json:"drifts"`
+	Repaired  int      `json:"repaired"`
+	Errors    []string `json:"errors,omitempty"`
+	StartedAt string   `json:"startedAt"`
+	Duration  string   `json:"duration"`
+}
+
+// Drift is one item that differs: missing on the platform, changed there or
+// unknown to storage. Repair is the action taken, empty when nothing was done.
+type Drift struct {
+	Kind              string   `json:"kind"`
+	ProductID         int64    `json:"productID,omitempty"`
+	PlatformProductID int      `json:"platformProductID,omitempty"`
+	Title             string   `json:"title,omitempty"`
+	Fields            []string `json:"fields,omitempty"`
+	Repair            string   `json:"repair,omitempty"`
+}
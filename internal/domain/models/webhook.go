@@ -0,0 +1,46 @@
+package models
+
+import "encoding/json"
+
+// Events sent to webhooks, named after the final stages of jobs.
+const (
+	WebhookEventPublished   = "published"
+	WebhookEventFailed      = "failed"
+	WebhookEventUnpublished = "unpublished"
+)
+
+const (
+	WebhookStatusPending   = "pending"
+	WebhookStatusDelivered = "delivered"
+	WebhookStatusFailed    = "failed"
+)
+
+// WebhookEvent is the body of a webhook: the outcome of a job on a platform.
+type WebhookEvent struct {
+	Event             string `json:"event"`
+	JobID             int64  `json:"jobID"`
+	ProductID         int64  `json:"productID"`
+	Title             string `json:"title,omitempty"`
+	Platform          string `json:"platform"`
+	Action            string `json:"action"`
+	PlatformProductID int    `json:"platformProductID,omitempty"`
+	Attempts          int    `json:"attempts"`
+	Error             string `json:"error,omitempty"`
+	OccurredAt        string `json:"occurredAt"`
+}
+
+// WebhookDelivery is a record of the delivery log: one event sent to one URL.
+type WebhookDelivery struct {
+	ID           int64           `json:"id"`
+	Event        string          `json:"event"`
+	URL          string          `json:"url"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"responseCode,omitempty"`
+	LastError    string          `json:"lastError,omitempty"`
+
+	AvailableAt string `json:"availableAt,omitempty"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
@@ -45,7 +45,7 @@ func ValidationError(errs validator.ValidationErrors) Response {
 
 	for _, err := range errs {
 		switch err.ActualTag() {
-		case "required":
+		case "required", "required_without":
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is missed", err.Field()))
 		default:
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not valid", err.Field()))
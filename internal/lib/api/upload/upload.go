@@ -0,0 +1,49 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+)
+
+type Uploader interface {
+	Upload(ctx context.Context, data []byte) (string, error)
+}
+
+// Pictures stores the uploaded files as pictures and returns their IDs in the
+// order of the files.
+func Pictures(ctx context.Context, uploader Uploader, files []*multipart.FileHeader) ([]string, error) {
+	ids := make([]string, 0, len(files))
+
+	for _, header := range files {
+		data, err := read(header)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := uploader.Upload(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Filename, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func read(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", header.Filename, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", header.Filename, err)
+	}
+
+	return data, nil
+}
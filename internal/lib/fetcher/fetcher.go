@@ -0,0 +1,393 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	dialTimeout = 10 * time.Second
+	// sniffLen is how much of the body http.DetectContentType looks at.
+	sniffLen = 512
+)
+
+// ContentTypes are the pictures the fetcher accepts, the ones the picture
+// manager can decode.
+var ContentTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+var (
+	ErrBlocked          = errors.New("destination is not allowed")
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrTooLarge         = errors.New("response is too large")
+	ErrContentType      = errors.New("unexpected content type")
+)
+
+// reserved are the non-public networks netip doesn't report on its own.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// StatusError is returned when the host answers with a non 200 status.
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %s", e.Status)
+}
+
+// Options limit what the fetcher downloads. TrustedURLs are URL prefixes,
+// e.g. the pictures of this server, that may point to a private address. Only
+// the exact scheme, host and port of a prefix are trusted and only for the
+// paths under it, e.g. http://localhost:8080/api/v1/pictures/.
+type Options struct {
+	Timeout      time.Duration
+	MaxSize      int64
+	MaxRedirects int
+	PerHost      int
+	TrustedURLs  []string
+}
+
+func (o Options) Validate() error {
+	if o.Timeout <= 0 {
+		return fmt.Errorf("fetch timeout must be positive")
+	}
+
+	if o.MaxSize <= 0 {
+		return fmt.Errorf("fetch max size must be positive")
+	}
+
+	if o.MaxRedirects < 0 {
+		return fmt.Errorf("fetch max redirects must not be negative")
+	}
+
+	if o.PerHost <= 0 {
+		return fmt.Errorf("fetch per host limit must be positive")
+	}
+
+	for _, trusted := range o.TrustedURLs {
+		if _, err := parseTrusted(trusted); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fetcher downloads pictures from user supplied URLs. Only http and https
+// public addresses are dialed, the address is checked after the name is
+// resolved, so a name pointing to the local network is refused too.
+type Fetcher struct {
+	client  *http.Client
+	opts    Options
+	trusted []trustedURL
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlots
+}
+
+// trustedURL is a parsed prefix of Options.TrustedURLs, addr is host:port.
+type trustedURL struct {
+	scheme string
+	addr   string
+	path   string
+}
+
+// hostSlots limits the concurrent requests to a host, users counts the
+// requests holding or waiting for a slot, the entry is dropped at 0.
+type hostSlots struct {
+	slots chan struct{}
+	users int
+}
+
+func New(opts Options) *Fetcher {
+	f := &Fetcher{
+		opts:  opts,
+		hosts: make(map[string]*hostSlots),
+	}
+
+	for _, raw := range opts.TrustedURLs {
+		// invalid prefixes are refused by Validate, skipping one trusts less
+		if trusted, err := parseTrusted(raw); err == nil {
+			f.trusted = append(f.trusted, trusted)
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	publicDialer := &net.Dialer{Timeout: dialTimeout, Control: checkAddress}
+
+	f.client = &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			// a proxy would dial instead of us, the addresses wouldn't be checked
+			Proxy: nil,
+			DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				// every URL of a trusted address is checked by checkURL, so
+				// its connections only ever carry trusted requests
+				if f.trustedAddr(addr) {
+					return dialer.DialContext(ctx, network, addr)
+				}
+
+				return publicDialer.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: dialTimeout,
+			MaxIdleConnsPerHost: opts.PerHost,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("%w: more than %d", ErrTooManyRedirects, opts.MaxRedirects)
+			}
+
+			return f.checkURL(req.URL)
+		},
+	}
+
+	return f
+}
+
+// Fetch downloads the picture at the URL. The body must be one of
+// ContentTypes and at most MaxSize bytes.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid url: %w", ErrBlocked, err)
+	}
+
+	if err := f.checkURL(parsed); err != nil {
+		return nil, err
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+
+	if err := f.acquire(ctx, host); err != nil {
+		return nil, err
+	}
+	defer f.release(host)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", strings.Join(ContentTypes, ", "))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	// the header is checked first to not download a page, octet-stream is
+	// left to the sniffing since some hosts send pictures as it
+	if contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil &&
+		contentType != "application/octet-stream" && !slices.Contains(ContentTypes, contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrContentType, contentType)
+	}
+
+	if resp.ContentLength > f.opts.MaxSize {
+		return nil, fmt.Errorf("%w: %d bytes, max %d", ErrTooLarge, resp.ContentLength, f.opts.MaxSize)
+	}
+
+	var body bytes.Buffer
+
+	n, err := io.Copy(&body, io.LimitReader(resp.Body, f.opts.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	if n > f.opts.MaxSize {
+		return nil, fmt.Errorf("%w: max %d bytes", ErrTooLarge, f.opts.MaxSize)
+	}
+
+	data := body.Bytes()
+
+	if contentType := http.DetectContentType(data[:min(len(data), sniffLen)]); !slices.Contains(ContentTypes, contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrContentType, contentType)
+	}
+
+	return data, nil
+}
+
+// checkURL refuses URLs that are not http or https and URLs of a trusted
+// address outside of its trusted prefixes.
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if err := checkScheme(u); err != nil {
+		return err
+	}
+
+	addr := hostPort(u)
+
+	if !f.trustedAddr(addr) {
+		return nil
+	}
+
+	// a dot segment could climb out of the prefix on the server side
+	clean := path.Clean(u.Path) == u.Path
+
+	for _, trusted := range f.trusted {
+		if clean && trusted.scheme == u.Scheme && strings.EqualFold(trusted.addr, addr) && strings.HasPrefix(u.Path, trusted.path) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s is trusted only for its prefixes", ErrBlocked, addr)
+}
+
+func (f *Fetcher) trustedAddr(addr string) bool {
+	return slices.ContainsFunc(f.trusted, func(trusted trustedURL) bool {
+		return strings.EqualFold(trusted.addr, addr)
+	})
+}
+
+// acquire waits for a free slot of the host.
+func (f *Fetcher) acquire(ctx context.Context, host string) error {
+	f.mu.Lock()
+	h, ok := f.hosts[host]
+	if !ok {
+		h = &hostSlots{slots: make(chan struct{}, f.opts.PerHost)}
+		f.hosts[host] = h
+	}
+	h.users++
+	f.mu.Unlock()
+
+	select {
+	case h.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		f.leave(host, h)
+		return ctx.Err()
+	}
+}
+
+func (f *Fetcher) release(host string) {
+	f.mu.Lock()
+	h := f.hosts[host]
+	f.mu.Unlock()
+
+	<-h.slots
+
+	f.leave(host, h)
+}
+
+func (f *Fetcher) leave(host string, h *hostSlots) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h.users--
+	if h.users == 0 {
+		delete(f.hosts, host)
+	}
+}
+
+// IsPermanent reports whether fetching the URL again won't help: the
+// destination is refused, the body is not a picture or too large, or the host
+// answers with a client error.
+func IsPermanent(err error) bool {
+	if errors.Is(err, ErrBlocked) || errors.Is(err, ErrTooManyRedirects) ||
+		errors.Is(err, ErrTooLarge) || errors.Is(err, ErrContentType) {
+		return true
+	}
+
+	var stErr *StatusError
+	if errors.As(err, &stErr) {
+		return stErr.Code >= 400 && stErr.Code < 500 && stErr.Code != http.StatusTooManyRequests
+	}
+
+	return false
+}
+
+func parseTrusted(raw string) (trustedURL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || checkScheme(u) != nil {
+		return trustedURL{}, fmt.Errorf("invalid fetch trusted URL: %s", raw)
+	}
+
+	if u.Path != "/" && path.Clean(u.Path)+"/" != u.Path {
+		return trustedURL{}, fmt.Errorf("fetch trusted URL must be a clean path ending with /: %s", raw)
+	}
+
+	return trustedURL{scheme: u.Scheme, addr: hostPort(u), path: u.Path}, nil
+}
+
+// hostPort is the address the URL is dialed at, the port of the scheme when
+// it's not set.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlocked, u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: empty host", ErrBlocked)
+	}
+
+	return nil
+}
+
+// checkAddress is the dialer control, it runs with the resolved address
+// right before the connection is made.
+func checkAddress(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlocked, address)
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlocked, address)
+	}
+
+	if !public(addr) {
+		return fmt.Errorf("%w: %s is not a public address", ErrBlocked, addr)
+	}
+
+	return nil
+}
+
+// public reports whether the address is a public unicast one: not loopback,
+// private, link-local (cloud metadata), multicast or reserved.
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	return !slices.ContainsFunc(reserved, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
@@ -0,0 +1,182 @@
+package fetcher_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"prodLoaderREST/internal/lib/fetcher"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func newFetcher(t *testing.T, trusted ...string) *fetcher.Fetcher {
+	t.Helper()
+
+	opts := fetcher.Options{
+		Timeout:      5 * time.Second,
+		MaxSize:      1000,
+		MaxRedirects: 2,
+		PerHost:      2,
+		TrustedURLs:  trusted,
+	}
+
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return fetcher.New(opts)
+}
+
+func TestFetchTrusted(t *testing.T) {
+	pic := testPNG(t)
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pic)
+	}))
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/pictures/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pic)
+	})
+	mux.HandleFunc("/secret", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pic)
+	})
+	mux.HandleFunc("/api/v1/pictures/to-other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/pic", http.StatusFound)
+	})
+	mux.HandleFunc("/api/v1/pictures/to-secret", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/secret", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := newFetcher(t, srv.URL+"/api/v1/pictures/")
+
+	data, err := f.Fetch(context.Background(), srv.URL+"/api/v1/pictures/abc")
+	if err != nil {
+		t.Fatalf("trusted prefix: %v", err)
+	}
+
+	if !bytes.Equal(data, pic) {
+		t.Fatal("trusted prefix: unexpected body")
+	}
+
+	blocked := map[string]string{
+		"other path":                srv.URL + "/secret",
+		"dot segments":              srv.URL + "/api/v1/pictures/../../../secret",
+		"loopback on another port":  other.URL + "/api/v1/pictures/abc",
+		"redirect to another port":  srv.URL + "/api/v1/pictures/to-other",
+		"redirect out of prefix":    srv.URL + "/api/v1/pictures/to-secret",
+		"another scheme":            "https" + srv.URL[len("http"):] + "/api/v1/pictures/abc",
+		"private address":           "http://10.0.0.1/api/v1/pictures/abc",
+		"link-local metadata":       "http://169.254.169.254/latest/meta-data",
+		"mapped private address":    "http://[::ffff:192.168.0.1]/x",
+		"unsupported scheme":        "file:///etc/passwd",
+		"loopback without trusting": "http://localhost:1/x",
+	}
+
+	for name, u := range blocked {
+		t.Run(name, func(t *testing.T) {
+			_, err := f.Fetch(context.Background(), u)
+			if !errors.Is(err, fetcher.ErrBlocked) {
+				t.Fatalf("expected ErrBlocked, got %v", err)
+			}
+
+			if !fetcher.IsPermanent(err) {
+				t.Fatal("blocked fetch is not permanent")
+			}
+		})
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	pic := testPNG(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/p/pic", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pic)
+	})
+	mux.HandleFunc("/p/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/p/octet", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("not a picture"))
+	})
+	mux.HandleFunc("/p/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat(pic, 100))
+	})
+	mux.HandleFunc("/p/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/p/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/p/missing", http.NotFound)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := newFetcher(t, srv.URL+"/p/")
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/p/pic"); err != nil {
+		t.Fatalf("picture: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		err  error
+	}{
+		{"/p/html", fetcher.ErrContentType},
+		{"/p/octet", fetcher.ErrContentType},
+		{"/p/big", fetcher.ErrTooLarge},
+		{"/p/loop", fetcher.ErrTooManyRedirects},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := f.Fetch(context.Background(), srv.URL+tt.path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	_, err := f.Fetch(context.Background(), srv.URL+"/p/missing")
+
+	var stErr *fetcher.StatusError
+	if !errors.As(err, &stErr) || stErr.Code != http.StatusNotFound || !fetcher.IsPermanent(err) {
+		t.Fatalf("expected permanent 404, got %v", err)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	valid := fetcher.Options{Timeout: time.Second, MaxSize: 1, PerHost: 1}
+
+	for _, trusted := range []string{"http://localhost:8080", "http://localhost:8080/pictures", "ftp://localhost/", "http://localhost/a/../b/"} {
+		opts := valid
+		opts.TrustedURLs = []string{trusted}
+
+		if err := opts.Validate(); err == nil {
+			t.Errorf("trusted URL %s is accepted", trusted)
+		}
+	}
+
+	valid.TrustedURLs = []string{"http://localhost:8080/api/v1/pictures/"}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+package avito
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"prodLoaderREST/internal/broker"
+	"prodLoaderREST/internal/domain/models"
+	"time"
+)
+
+type Storage interface {
+	AddToAvitoFeed(ctx context.Context, product *models.Product) error
+	RemoveFromAvitoFeed(ctx context.Context, productID int64) error
+	AvitoFeed(ctx context.Context) ([]*models.Product, error)
+	InAvitoFeed(ctx context.Context, productID int64) (bool, error)
+	Product(ctx context.Context, productID int64) (*models.Product, error)
+	AvitoLoaded(productID int64, avitoProductID int) error
+	AvitoDeleted(productID int64) error
+}
+
+// FeedOptions are the ad fields that are the same for every product of the shop.
+type FeedOptions struct {
+	Category     string
+	GoodsType    string
+	AdType       string
+	Condition    string
+	Address      string
+	ContactPhone string
+}
+
+// Consumer publishes products to Avito through the Autoload feed: jobs only
+// change the feed, Avito polls it and reports the result of every load.
+type Consumer struct {
+	log            *slog.Logger
+	storage        Storage
+	client         *Client
+	opts           FeedOptions
+	reportInterval time.Duration
+}
+
+// New creates the consumer, client is nil when Autoload reports are not configured.
+func New(log *slog.Logger, storage Storage, client *Client, opts FeedOptions, reportInterval time.Duration) *Consumer {
+	return &Consumer{
+		log:            log,
+		storage:        storage,
+		client:         client,
+		opts:           opts,
+		reportInterval: reportInterval,
+	}
+}
+
+func (c *Consumer) Name() string {
+	return models.PlatformAvito
+}
+
+// Health checks that the client credentials are accepted, the feed itself
+// needs no API access.
+func (c *Consumer) Health(ctx context.Context) error {
+	if c.client == nil {
+		return nil
+	}
+
+	if _, err := c.client.accessToken(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Run syncs Autoload reports every report interval until ctx is cancelled.
+func (c *Consumer) Run(ctx context.Context) {
+	if c.client == nil {
+		c.log.Warn("avito api is not configured, autoload reports are not synced")
+		return
+	}
+
+	ticker := time.NewTicker(c.reportInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.SyncReport(ctx); err != nil {
+			c.log.Error("failed to sync avito report", "err", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Consumer) Publish(ctx context.Context, p *models.Product) error {
+	if p == nil {
+		c.log.Error("Received nil product")
+		return broker.Permanent(fmt.Errorf("nil product"))
+	}
+
+	if err := c.storage.AddToAvitoFeed(ctx, p); err != nil {
+		c.log.Error("failed to add product to avito feed", "productID", p.Id, "err", err.Error())
+		return fmt.Errorf("failed to add product to feed: %w", err)
+	}
+
+	c.log.Debug("Product added to avito feed", "productID", p.Id)
+
+	return nil
+}
+
+// Update rewrites the ad in the feed, Avito applies it on the next load.
+func (c *Consumer) Update(ctx context.Context, p *models.Product) error {
+	return c.Publish(ctx, p)
+}
+
+func (c *Consumer) Unpublish(ctx context.Context, productID int64, avitoProductID int) error {
+	// Autoload closes the ads that are missing in the feed on the next load
+	if err := c.storage.RemoveFromAvitoFeed(ctx, productID); err != nil {
+		c.log.Error("failed to remove product from avito feed", "productID", productID, "err", err.Error())
+		return fmt.Errorf("failed to remove product from feed: %w", err)
+	}
+
+	if err := c.storage.AvitoDeleted(productID); err != nil {
+		c.log.Error("Failed to delete product from storage", "productID", productID, "err", err)
+		return fmt.Errorf("failed to change status: %w", err)
+	}
+
+	c.log.Debug("product removed from avito feed", "productID", productID)
+
+	return nil
+}
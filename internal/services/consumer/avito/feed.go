@@ -0,0 +1,117 @@
+package avito
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"prodLoaderREST/internal/domain/models"
+	"strconv"
+)
+
+const (
+	feedFormatVersion = "3"
+	feedTarget        = "Avito.ru"
+	maxTitleLength    = 50
+	maxImages         = 10
+)
+
+type feed struct {
+	XMLName       xml.Name `xml:"Ads"`
+	FormatVersion string   `xml:"formatVersion,attr"`
+	Target        string   `xml:"target,attr"`
+	Ads           []ad     `xml:"Ad"`
+}
+
+type ad struct {
+	ID           string `xml:"Id"`
+	Category     string `xml:"Category"`
+	GoodsType    string `xml:"GoodsType,omitempty"`
+	AdType       string `xml:"AdType,omitempty"`
+	Condition    string `xml:"Condition,omitempty"`
+	Address      string `xml:"Address"`
+	ContactPhone string `xml:"ContactPhone,omitempty"`
+	Title        string `xml:"Title"`
+	Description  cdata  `xml:"Description"`
+	Price        int    `xml:"Price"`
+	Size         string `xml:"Size,omitempty"`
+	Images       images `xml:"Images"`
+}
+
+type cdata struct {
+	Text string `xml:",cdata"`
+}
+
+type images struct {
+	Image []image `xml:"Image"`
+}
+
+type image struct {
+	URL string `xml:"url,attr"`
+}
+
+// WriteFeed writes the Autoload XML feed with every product added to Avito.
+func (c *Consumer) WriteFeed(ctx context.Context, w io.Writer) error {
+	products, err := c.storage.AvitoFeed(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get feed products: %w", err)
+	}
+
+	f := feed{
+		FormatVersion: feedFormatVersion,
+		Target:        feedTarget,
+		Ads:           make([]ad, 0, len(products)),
+	}
+
+	for _, p := range products {
+		f.Ads = append(f.Ads, c.ad(p))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(f); err != nil {
+		return fmt.Errorf("failed to encode feed: %w", err)
+	}
+
+	return enc.Close()
+}
+
+func (c *Consumer) ad(p *models.Product) ad {
+	title := []rune(p.Title)
+	if len(title) > maxTitleLength {
+		title = title[:maxTitleLength]
+	}
+
+	a := ad{
+		ID:           strconv.FormatInt(p.Id, 10),
+		Category:     c.opts.Category,
+		GoodsType:    c.opts.GoodsType,
+		AdType:       c.opts.AdType,
+		Condition:    c.opts.Condition,
+		Address:      c.opts.Address,
+		ContactPhone: c.opts.ContactPhone,
+		Title:        string(title),
+		Description:  cdata{Text: p.Description},
+		Price:        p.Price,
+		Size:         p.Size,
+	}
+
+	for _, url := range append([]string{p.MainPictureURL}, p.PicturesURL...) {
+		if len(a.Images.Image) == maxImages {
+			break
+		}
+
+		if url == "" {
+			continue
+		}
+
+		a.Images.Image = append(a.Images.Image, image{URL: url})
+	}
+
+	return a
+}
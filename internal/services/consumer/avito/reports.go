@@ -0,0 +1,235 @@
+package avito
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"prodLoaderREST/internal/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tokenPath            = "/token"
+	lastReportPath       = "/autoload/v2/reports/last_completed_report"
+	reportItemsPathFmt   = "/autoload/v2/reports/%d/items"
+	reportItemsPerPage   = 200
+	messageTypeError     = "error"
+	tokenRefreshInterval = time.Minute
+)
+
+// Client is a client of the Avito Autoload API authorized with client credentials.
+type Client struct {
+	httpClient   *http.Client
+	apiURL       string
+	clientID     string
+	clientSecret string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func NewClient(apiURL string, clientID string, clientSecret string) *Client {
+	return &Client{
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		apiURL:       strings.TrimRight(apiURL, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+	}
+}
+
+type reportItem struct {
+	AdID     string `json:"ad_id"`
+	AvitoID  int    `json:"avito_id"`
+	Messages []struct {
+		Type        string `json:"type"`
+		Description string `json:"description"`
+	} `json:"messages"`
+}
+
+type reportItemsResponse struct {
+	Items []reportItem `json:"items"`
+	Meta  struct {
+		Page  int `json:"page"`
+		Pages int `json:"pages"`
+	} `json:"meta"`
+}
+
+// SyncReport reads the last completed Autoload report and saves the Avito ID
+// of every ad that was published, ads that failed without an ID are unloaded.
+func (c *Consumer) SyncReport(ctx context.Context) error {
+	var last struct {
+		ReportID int64 `json:"report_id"`
+	}
+
+	if err := c.client.get(ctx, lastReportPath, nil, &last); err != nil {
+		return fmt.Errorf("failed to get last report: %w", err)
+	}
+
+	log := c.log.With("reportID", last.ReportID)
+
+	for page := 0; ; page++ {
+		query := url.Values{}
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(reportItemsPerPage))
+
+		var resp reportItemsResponse
+
+		if err := c.client.get(ctx, fmt.Sprintf(reportItemsPathFmt, last.ReportID), query, &resp); err != nil {
+			return fmt.Errorf("failed to get report items: %w", err)
+		}
+
+		for _, item := range resp.Items {
+			c.applyReportItem(ctx, log, item)
+		}
+
+		if page+1 >= resp.Meta.Pages {
+			break
+		}
+	}
+
+	log.Debug("avito report synced")
+
+	return nil
+}
+
+// applyReportItem saves the state of the ad from the report. A report can
+// come after the product was deleted or removed from the feed, such ads are
+// only cleared, they must not look loaded again.
+func (c *Consumer) applyReportItem(ctx context.Context, log *slog.Logger, item reportItem) {
+	productID, err := strconv.ParseInt(item.AdID, 10, 64)
+	if err != nil {
+		log.Warn("unknown ad in avito report", "adID", item.AdID)
+		return
+	}
+
+	var errs []string
+	for _, m := range item.Messages {
+		if m.Type == messageTypeError {
+			errs = append(errs, m.Description)
+		}
+	}
+
+	inFeed, err := c.inFeed(ctx, productID)
+	if err != nil {
+		log.Error("failed to check avito feed", "productID", productID, "err", err.Error())
+		return
+	}
+
+	switch {
+	case !inFeed:
+		log.Debug("ad is not in avito feed anymore", "productID", productID)
+		err = c.storage.AvitoDeleted(productID)
+	case item.AvitoID != 0 && len(errs) == 0:
+		err = c.storage.AvitoLoaded(productID, item.AvitoID)
+	case item.AvitoID == 0 && len(errs) > 0:
+		log.Warn("avito rejected ad", "productID", productID, "errors", strings.Join(errs, "; "))
+		err = c.storage.AvitoDeleted(productID)
+	}
+
+	if err != nil {
+		log.Error("failed to change status", "productID", productID, "err", err.Error())
+	}
+}
+
+// inFeed reports whether the product still exists, is meant for Avito and
+// is in the feed.
+func (c *Consumer) inFeed(ctx context.Context, productID int64) (bool, error) {
+	p, err := c.storage.Product(ctx, productID)
+	if errors.Is(err, storage.ErrProductIDnotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !p.Avito.ToLoad {
+		return false, nil
+	}
+
+	return c.storage.InAvitoFeed(ctx, productID)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, dst any) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	u := c.apiURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// accessToken returns a cached token and requests a new one shortly before it expires.
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", c.clientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+tokenPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get avito token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get avito token: unexpected status: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode avito token: %w", err)
+	}
+
+	c.token = token.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenRefreshInterval)
+
+	return c.token, nil
+}
@@ -0,0 +1,30 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"prodLoaderREST/internal/domain/models"
+)
+
+var ErrNotSupported = errors.New("operation is not supported by marketplace")
+
+// Marketplace is a platform the products are published to. Name is used to
+// route jobs of the platform, it must match models.Platform* constants.
+type Marketplace interface {
+	Name() string
+	Publish(ctx context.Context, product *models.Product) error
+	Update(ctx context.Context, product *models.Product) error
+	Unpublish(ctx context.Context, productID int64, platformProductID int) error
+	Health(ctx context.Context) error
+}
+
+// Runner is implemented by marketplaces that have background work besides jobs.
+type Runner interface {
+	Run(ctx context.Context)
+}
+
+// Limited is implemented by marketplaces that set how many jobs they handle at
+// once, the others get a default pool. The rest wait in the outbox.
+type Limited interface {
+	Concurrency() int
+}
@@ -9,3 +9,16 @@ var Categories = []map[string]int{
 	{"Сумки": 85},
 	{"Аксессуары": 88},
 }
+
+// CategoryName returns the name of the shop category with the given ID.
+func CategoryName(id int) (string, bool) {
+	for _, category := range Categories {
+		for name, categoryID := range category {
+			if categoryID == id {
+				return name, true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -1,13 +1,36 @@
 package ucoz
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"prodLoaderREST/internal/broker"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/fetcher"
+	"prodLoaderREST/internal/services/consumer/ucoz/types"
+	"strconv"
+	"strings"
 
 	"github.com/dghubble/oauth1"
 )
 
+const (
+	goodsPath      = "/shop/editgoods"
+	categoriesPath = "/shop/request?page=categories"
+	maxPictures    = 5
+)
+
+var (
+	ErrUnknownCategory = errors.New("unknown ucoz category")
+)
+
 type Client struct {
 	authConfig *oauth1.Config
 	authToken  *oauth1.Token
@@ -15,7 +38,7 @@ type Client struct {
 	log        *slog.Logger
 }
 
-func New(log *slog.Logger, consumerKey, consumerSecret, token, tokenSecret string) *Client {
+func NewClient(log *slog.Logger, consumerKey, consumerSecret, token, tokenSecret string) *Client {
 	authConfig := oauth1.NewConfig(consumerKey, consumerSecret)
 	authToken := oauth1.NewToken(token, tokenSecret)
 
@@ -29,3 +52,288 @@ func New(log *slog.Logger, consumerKey, consumerSecret, token, tokenSecret strin
 	}
 
 }
+
+type StatusChanger interface {
+	UcozLoaded(productID int64, ucozProductID int) error
+	UcozDeleted(productID int64) error
+	UcozProductID(productID int64) (int, error)
+}
+
+type Consumer struct {
+	log           *slog.Logger
+	client        *Client
+	apiURL        string
+	statusChanger StatusChanger
+	fetcher       Fetcher
+}
+
+// Fetcher downloads the pictures attached to the goods.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) ([]byte, error)
+}
+
+// New creates a consumer of the uCoz shop API, apiURL is the uAPI root of the
+// site, e.g. https://example.ucoz.net/uapi
+func New(log *slog.Logger, client *Client, apiURL string, statusChanger StatusChanger, fetcher Fetcher) *Consumer {
+	return &Consumer{
+		log:           log,
+		client:        client,
+		apiURL:        strings.TrimRight(apiURL, "/"),
+		statusChanger: statusChanger,
+		fetcher:       fetcher,
+	}
+}
+
+// apiResponse is the envelope of uAPI answers: either success or error is set.
+type apiResponse struct {
+	Success *struct {
+		ID int `json:"id"`
+	} `json:"success"`
+	Error *apiError `json:"error"`
+}
+
+type apiError struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("ucoz api error %s: %s", e.Code, e.Msg)
+}
+
+func (c *Consumer) Name() string {
+	return models.PlatformUcoz
+}
+
+// Health checks that the site answers signed requests.
+func (c *Consumer) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiURL+categoriesPath, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.do(req); err != nil {
+		return fmt.Errorf("failed to get ucoz categories: %w", err)
+	}
+
+	return nil
+}
+
+// Update edits the goods in the shop, the category is changed only when it is set.
+func (c *Consumer) Update(ctx context.Context, p *models.Product) error {
+	if p == nil {
+		return broker.Permanent(fmt.Errorf("nil product"))
+	}
+
+	log := c.log.With("Title", p.Title)
+
+	ucozProductID, err := c.statusChanger.UcozProductID(p.Id)
+	if err != nil {
+		return fmt.Errorf("failed to get ucoz product id: %w", err)
+	}
+
+	if ucozProductID == 0 {
+		return broker.Permanent(fmt.Errorf("product %d is not published to ucoz", p.Id))
+	}
+
+	fields := goodsFields(p)
+	fields["id"] = strconv.Itoa(ucozProductID)
+
+	if p.Ucoz.CategoryID == 0 {
+		delete(fields, "cat_id")
+	}
+
+	broker.ReportStage(ctx, models.JobStageUploadingPictures)
+
+	req, err := c.goodsRequest(ctx, http.MethodPut, p, fields)
+	if err != nil {
+		log.Error("Failed to create request", "err", err.Error())
+		return err
+	}
+
+	broker.ReportStage(ctx, models.JobStagePublishing)
+
+	if _, err := c.do(req); err != nil {
+		log.Error("Failed to edit product in shop", "err", err.Error())
+		return fmt.Errorf("failed to edit product in shop: %w", err)
+	}
+
+	log.Debug("Product edited in ucoz shop", "ucozProductID", ucozProductID)
+
+	return nil
+}
+
+func (c *Consumer) Publish(ctx context.Context, p *models.Product) error {
+	if p == nil {
+		c.log.Error("Received nil product")
+		return broker.Permanent(fmt.Errorf("nil product"))
+	}
+
+	log := c.log.With("Title", p.Title)
+
+	if _, ok := types.CategoryName(p.Ucoz.CategoryID); !ok {
+		log.Error("unknown category", "categoryID", p.Ucoz.CategoryID)
+		return broker.Permanent(fmt.Errorf("%w: %d", ErrUnknownCategory, p.Ucoz.CategoryID))
+	}
+
+	broker.ReportStage(ctx, models.JobStageUploadingPictures)
+
+	req, err := c.goodsRequest(ctx, http.MethodPost, p, goodsFields(p))
+	if err != nil {
+		log.Error("Failed to create request", "err", err.Error())
+		return err
+	}
+
+	broker.ReportStage(ctx, models.JobStagePublishing)
+
+	resp, err := c.do(req)
+	if err != nil {
+		log.Error("Failed to add product to shop", "err", err.Error())
+		return fmt.Errorf("failed to add product to shop: %w", err)
+	}
+
+	if resp.Success == nil || resp.Success.ID == 0 {
+		log.Error("no product ID in ucoz response")
+		// the item may be added anyway, retrying could add a duplicate
+		return broker.Permanent(fmt.Errorf("no product ID in ucoz response"))
+	}
+
+	err = c.statusChanger.UcozLoaded(p.Id, resp.Success.ID)
+	if err != nil {
+		log.Error("failed to change status", "error", err)
+		// the item is already in the shop, retrying would add a duplicate
+		return broker.Permanent(fmt.Errorf("failed to change status: %w", err))
+	}
+
+	log.Debug("Product added to ucoz shop. ID Saved in storage", "ucozProductID", resp.Success.ID)
+
+	return nil
+}
+
+func goodsFields(p *models.Product) map[string]string {
+	return map[string]string{
+		"cat_id":      strconv.Itoa(p.Ucoz.CategoryID),
+		"name":        p.Title,
+		"price":       strconv.Itoa(p.Price),
+		"description": p.Description,
+		"brief":       strings.Split(p.Description, "\n")[0],
+	}
+}
+
+// goodsRequest builds the multipart request of the goods with its pictures attached.
+func (c *Consumer) goodsRequest(ctx context.Context, method string, p *models.Product, fields map[string]string) (*http.Request, error) {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return nil, fmt.Errorf("failed to write form: %w", err)
+		}
+	}
+
+	pictures := append([]string{p.MainPictureURL}, p.PicturesURL...)
+
+	n := 0
+	for _, picURL := range pictures {
+		if n == maxPictures {
+			break
+		}
+
+		if picURL == "" {
+			continue
+		}
+
+		n++
+
+		if err := c.attachPicture(ctx, form, fmt.Sprintf("file%d", n), picURL); err != nil {
+			return nil, fmt.Errorf("failed to load picture %s: %w", picURL, err)
+		}
+	}
+
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write form: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+goodsPath, body)
+	if err != nil {
+		return nil, broker.Permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	return req, nil
+}
+
+func (c *Consumer) Unpublish(ctx context.Context, productID int64, ucozProductID int) error {
+	c.log.Debug("Recived product to delete", "productID", productID)
+
+	query := url.Values{}
+	query.Set("id", strconv.Itoa(ucozProductID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.apiURL+goodsPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return broker.Permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+
+	_, err = c.do(req)
+	if err != nil {
+		c.log.Error("Failed to delete product from shop", "productID", productID, "err", err.Error())
+		return fmt.Errorf("failed to delete product from shop: %w", err)
+	}
+
+	err = c.statusChanger.UcozDeleted(productID)
+	if err != nil {
+		c.log.Error("Failed to delete product from storage", "productID", productID, "err", err)
+		return fmt.Errorf("failed to change status: %w", err)
+	}
+
+	c.log.Debug("product deleted from ucoz", "productID", productID)
+
+	return nil
+}
+
+func (c *Consumer) attachPicture(ctx context.Context, form *multipart.Writer, field string, picURL string) error {
+	data, err := c.fetcher.Fetch(ctx, picURL)
+	if err != nil {
+		if fetcher.IsPermanent(err) {
+			return broker.Permanent(err)
+		}
+		return err
+	}
+
+	part, err := form.CreateFormFile(field, fmt.Sprintf("%s.jpg", field))
+	if err != nil {
+		return err
+	}
+
+	_, err = part.Write(data)
+
+	return err
+}
+
+// do sends the signed request and decodes the uAPI envelope. Client errors and
+// API errors are permanent, server errors and network failures are retryable.
+func (c *Consumer) do(req *http.Request) (*apiResponse, error) {
+	resp, err := c.client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+
+	decodeErr := json.NewDecoder(resp.Body).Decode(&result)
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	case result.Error != nil:
+		return nil, broker.Permanent(result.Error)
+	case resp.StatusCode >= 400:
+		return nil, broker.Permanent(fmt.Errorf("unexpected status: %s", resp.Status))
+	case decodeErr != nil && !errors.Is(decodeErr, io.EOF):
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,283 @@
+package ucoz_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"prodLoaderREST/internal/broker"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/lib/fetcher"
+	"prodLoaderREST/internal/services/consumer/ucoz"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// shop is a stand-in of the uAPI shop, it keeps the last goods request.
+type shop struct {
+	mu      sync.Mutex
+	method  string
+	fields  map[string]string
+	files   map[string]string
+	query   string
+	status  int
+	respond string
+}
+
+func (s *shop) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "OAuth ") {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":"auth","msg":"not signed"}}`))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.method = r.Method
+	s.query = r.URL.RawQuery
+	s.fields = make(map[string]string)
+	s.files = make(map[string]string)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		for name, values := range r.MultipartForm.Value {
+			s.fields[name] = values[0]
+		}
+
+		for name, headers := range r.MultipartForm.File {
+			f, _ := headers[0].Open()
+			data, _ := io.ReadAll(f)
+			f.Close()
+			s.files[name] = string(data)
+		}
+	}
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+	}
+
+	w.Write([]byte(s.respond))
+}
+
+type pictures map[string]string
+
+func (p pictures) Fetch(_ context.Context, rawURL string) ([]byte, error) {
+	data, ok := p[rawURL]
+	if !ok {
+		return nil, &fetcher.StatusError{Code: http.StatusNotFound, Status: "404 Not Found"}
+	}
+
+	return []byte(data), nil
+}
+
+type statuses struct {
+	loaded  map[int64]int
+	deleted []int64
+}
+
+func (s *statuses) UcozLoaded(productID int64, ucozProductID int) error {
+	s.loaded[productID] = ucozProductID
+	return nil
+}
+
+func (s *statuses) UcozDeleted(productID int64) error {
+	s.deleted = append(s.deleted, productID)
+	return nil
+}
+
+func (s *statuses) UcozProductID(productID int64) (int, error) {
+	return s.loaded[productID], nil
+}
+
+func newConsumer(t *testing.T, respond string) (*ucoz.Consumer, *shop, *statuses) {
+	t.Helper()
+
+	sh := &shop{respond: respond}
+
+	srv := httptest.NewServer(sh)
+	t.Cleanup(srv.Close)
+
+	st := &statuses{loaded: make(map[int64]int)}
+	pics := pictures{"http://pics/main": "main", "http://pics/1": "first"}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := ucoz.NewClient(log, "key", "secret", "token", "token-secret")
+
+	return ucoz.New(log, client, srv.URL+"/uapi/", st, pics), sh, st
+}
+
+func testProduct() *models.Product {
+	return &models.Product{
+		Id:             1,
+		Title:          "кеды",
+		Description:    "белые кеды\nразмер 42",
+		Price:          3000,
+		MainPictureURL: "http://pics/main",
+		PicturesURL:    []string{"", "http://pics/1"},
+		Ucoz:           models.Ucoz{ToLoad: true, CategoryID: 86},
+	}
+}
+
+func TestPublish(t *testing.T) {
+	c, sh, st := newConsumer(t, `{"success":{"id":42}}`)
+
+	if err := c.Publish(context.Background(), testProduct()); err != nil {
+		t.Fatal(err)
+	}
+
+	if sh.method != http.MethodPost {
+		t.Fatalf("expected POST, got %s", sh.method)
+	}
+
+	want := map[string]string{
+		"cat_id":      "86",
+		"name":        "кеды",
+		"price":       "3000",
+		"description": "белые кеды\nразмер 42",
+		"brief":       "белые кеды",
+	}
+
+	for name, value := range want {
+		if sh.fields[name] != value {
+			t.Errorf("field %s: expected %q, got %q", name, value, sh.fields[name])
+		}
+	}
+
+	// the empty URL is skipped, the files keep the order of the pictures
+	if sh.files["file1"] != "main" || sh.files["file2"] != "first" || len(sh.files) != 2 {
+		t.Errorf("unexpected files: %v", sh.files)
+	}
+
+	if st.loaded[1] != 42 {
+		t.Errorf("expected ucoz product 42 saved, got %d", st.loaded[1])
+	}
+}
+
+func TestPublishUnknownCategory(t *testing.T) {
+	c, sh, _ := newConsumer(t, `{"success":{"id":42}}`)
+
+	p := testProduct()
+	p.Ucoz.CategoryID = 1
+
+	err := c.Publish(context.Background(), p)
+	if !errors.Is(err, ucoz.ErrUnknownCategory) || !errors.Is(err, broker.ErrPermanent) {
+		t.Fatalf("expected permanent unknown category, got %v", err)
+	}
+
+	if sh.method != "" {
+		t.Fatal("request sent for an unknown category")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c, sh, st := newConsumer(t, `{"success":{"id":42}}`)
+
+	st.loaded[1] = 42
+
+	p := testProduct()
+	p.Ucoz.CategoryID = 0
+
+	if err := c.Update(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	if sh.method != http.MethodPut || sh.fields["id"] != "42" {
+		t.Fatalf("expected PUT of goods 42, got %s %v", sh.method, sh.fields)
+	}
+
+	if _, ok := sh.fields["cat_id"]; ok {
+		t.Error("category is sent while not set")
+	}
+
+	delete(st.loaded, 1)
+
+	if err := c.Update(context.Background(), p); !errors.Is(err, broker.ErrPermanent) {
+		t.Fatalf("expected permanent error for unpublished product, got %v", err)
+	}
+}
+
+func TestUnpublish(t *testing.T) {
+	c, sh, st := newConsumer(t, `{"success":{"id":42}}`)
+
+	if err := c.Unpublish(context.Background(), 1, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	if sh.method != http.MethodDelete || sh.query != "id=42" {
+		t.Fatalf("expected DELETE of goods 42, got %s %s", sh.method, sh.query)
+	}
+
+	if len(st.deleted) != 1 || st.deleted[0] != 1 {
+		t.Fatalf("expected product 1 deleted, got %v", st.deleted)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		respond   string
+		permanent bool
+	}{
+		{"server error", http.StatusBadGateway, ``, false},
+		{"too many requests", http.StatusTooManyRequests, ``, false},
+		{"api error", http.StatusOK, `{"error":{"code":"BAD_PARAM","msg":"wrong price"}}`, true},
+		{"client error", http.StatusBadRequest, ``, true},
+		{"no product ID", http.StatusOK, `{}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, sh, st := newConsumer(t, tt.respond)
+			sh.status = tt.status
+
+			err := c.Publish(context.Background(), testProduct())
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			if errors.Is(err, broker.ErrPermanent) != tt.permanent {
+				t.Fatalf("permanent %v expected, got %v", tt.permanent, err)
+			}
+
+			if len(st.loaded) != 0 {
+				t.Fatal("failed product is saved as loaded")
+			}
+		})
+	}
+}
+
+func TestMissingPicture(t *testing.T) {
+	c, sh, _ := newConsumer(t, `{"success":{"id":42}}`)
+
+	p := testProduct()
+	p.PicturesURL = []string{"http://pics/missing"}
+
+	if err := c.Publish(context.Background(), p); !errors.Is(err, broker.ErrPermanent) {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+
+	if sh.method != "" {
+		t.Fatal("request sent without the picture")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	c, sh, _ := newConsumer(t, `{"success":{"id":0}}`)
+
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if sh.method != http.MethodGet || sh.query != "page=categories" {
+		t.Fatalf("expected categories request, got %s %s", sh.method, sh.query)
+	}
+}
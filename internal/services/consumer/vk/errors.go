@@ -0,0 +1,46 @@
+package vk
+
+import (
+	"errors"
+	"prodLoaderREST/internal/broker"
+	"prodLoaderREST/internal/lib/fetcher"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+)
+
+// retryable VK errors: rate limits, flood control and server side failures.
+var retryableVkErrors = []error{
+	api.ErrUnknown,
+	api.ErrTooMany,
+	api.ErrFlood,
+	api.ErrServer,
+	api.ErrRateLimit,
+	api.ErrExecutionTimeout,
+	api.ErrUpload,
+}
+
+// classify marks errors that won't go away on retry as permanent. Everything
+// else (network errors, 5xx, unknown failures) stays retryable.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	for _, target := range retryableVkErrors {
+		if errors.Is(err, target) {
+			return err
+		}
+	}
+
+	var vkErr *api.Error
+	if errors.As(err, &vkErr) {
+		// invalid category, bad token, missing permissions, wrong params...
+		return broker.Permanent(err)
+	}
+
+	if fetcher.IsPermanent(err) {
+		return broker.Permanent(err)
+	}
+
+	return err
+}
@@ -0,0 +1,158 @@
+package vk
+
+import (
+	"context"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+	"sort"
+
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+// ImportMarket saves the items of the group market that no product is linked
+// to as new products linked to them, so they can be managed through the API.
+// The batch is saved before the items are imported, async returns it right away
+// and imports in the background, otherwise the finished batch is returned.
+func (v *Consumer) ImportMarket(ctx context.Context, async bool) (*models.ImportBatch, error) {
+	items, err := v.marketItems(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market items: %w", err)
+	}
+
+	links, err := v.storage.PlatformLinks(ctx, models.PlatformVK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vk products from storage: %w", err)
+	}
+
+	for _, link := range links {
+		delete(items, link.PlatformProductID)
+	}
+
+	unknown := make([]object.MarketMarketItem, 0, len(items))
+
+	for _, item := range items {
+		if item.Availability == availabilityRemoved {
+			continue
+		}
+
+		unknown = append(unknown, item)
+	}
+
+	sort.Slice(unknown, func(i, j int) bool {
+		return unknown[i].ID < unknown[j].ID
+	})
+
+	batch := &models.ImportBatch{
+		Filename: fmt.Sprintf("market of group %d", v.groupID),
+		Format:   models.ImportFormatVK,
+		Status:   models.ImportStatusProcessing,
+		Total:    len(unknown),
+		Valid:    len(unknown),
+		Rows:     make([]models.ImportRow, 0, len(unknown)),
+	}
+
+	for i, item := range unknown {
+		batch.Rows = append(batch.Rows, models.ImportRow{Row: i + 1, Title: item.Title})
+	}
+
+	if len(unknown) == 0 {
+		batch.Status = models.ImportStatusDone
+	}
+
+	if err := v.storage.SaveImportBatch(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to save import batch: %w", err)
+	}
+
+	if len(unknown) == 0 {
+		return batch, nil
+	}
+
+	if !async {
+		v.importItems(ctx, batch, unknown)
+		return batch, nil
+	}
+
+	// the copy is owned by the goroutine, the caller gets the batch as saved
+	result := *batch
+	result.Rows = append([]models.ImportRow(nil), batch.Rows...)
+
+	v.imports.Add(1)
+
+	go func() {
+		defer v.imports.Done()
+
+		v.importItems(context.WithoutCancel(ctx), batch, unknown)
+	}()
+
+	return &result, nil
+}
+
+// WaitImports blocks until the market imports running in the background are
+// done or ctx is cancelled, an unfinished batch is marked interrupted on the
+// next start.
+func (v *Consumer) WaitImports(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		v.imports.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (v *Consumer) importItems(ctx context.Context, batch *models.ImportBatch, items []object.MarketMarketItem) {
+	log := v.log.With("batchID", batch.ID)
+
+	log.Info("vk market import started", "items", len(items))
+
+	// the reconciler may have adopted some of the items meanwhile
+	v.reconcileMu.Lock()
+	defer v.reconcileMu.Unlock()
+
+	linked := make(map[int]int64)
+
+	links, err := v.storage.PlatformLinks(ctx, models.PlatformVK)
+	if err != nil {
+		log.Error("failed to get vk products from storage", "err", err.Error())
+	}
+
+	for _, link := range links {
+		linked[link.PlatformProductID] = link.ProductID
+	}
+
+	for n, item := range items {
+		if productID, ok := linked[item.ID]; ok {
+			batch.Rows[n].ProductID = productID
+			batch.Rows[n].Errors = []string{"item is already linked to a product"}
+			batch.Failed++
+			continue
+		}
+
+		productID, err := v.adopt(ctx, item)
+		if err != nil {
+			log.Error("failed to import market item", "vkProductID", item.ID, "err", err.Error())
+
+			batch.Rows[n].Errors = []string{err.Error()}
+			batch.Failed++
+			continue
+		}
+
+		batch.Rows[n].ProductID = productID
+		batch.Imported++
+	}
+
+	batch.Status = models.ImportStatusDone
+
+	if err := v.storage.UpdateImportBatch(ctx, batch); err != nil {
+		log.Error("failed to save import batch", "err", err.Error())
+		return
+	}
+
+	log.Info("vk market import finished", "imported", batch.Imported, "failed", batch.Failed)
+}
@@ -0,0 +1,345 @@
+package vk
+
+import (
+	"context"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SevereCloud/vksdk/api/params"
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+const (
+	marketPageSize = 200
+	// availabilityRemoved is the availability of an item deleted from the market.
+	availabilityRemoved = 1
+	// adoptGrace protects items that are being published right now: they are
+	// on the market already, but their ID is not saved yet.
+	adoptGrace = 10 * time.Minute
+)
+
+// ReconcileOptions set how often storage is compared with the market and what
+// is repaired. Missing is RepairMarkDeleted, RepairRepublish or empty to only
+// report items deleted from the market.
+type ReconcileOptions struct {
+	Interval time.Duration
+	Missing  string
+	Push     bool
+	Adopt    bool
+}
+
+func (o ReconcileOptions) Validate() error {
+	switch o.Missing {
+	case "", models.RepairMarkDeleted, models.RepairRepublish:
+		return nil
+	}
+
+	return fmt.Errorf("unknown repair of missing items: %s", o.Missing)
+}
+
+// Run reconciles storage with the market every interval until ctx is cancelled.
+func (v *Consumer) Run(ctx context.Context) {
+	if v.reconcile.Interval <= 0 {
+		v.log.Info("vk reconciliation is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(v.reconcile.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := v.Reconcile(ctx, true); err != nil {
+			v.log.Error("failed to reconcile vk market", "err", err.Error())
+		}
+	}
+}
+
+// Reconcile compares the items of the group market with storage. With repair
+// the drift is fixed as the options allow, otherwise it's only reported.
+func (v *Consumer) Reconcile(ctx context.Context, repair bool) (*models.ReconcileReport, error) {
+	v.reconcileMu.Lock()
+	defer v.reconcileMu.Unlock()
+
+	started := time.Now()
+
+	report := &models.ReconcileReport{
+		Platform:  models.PlatformVK,
+		Repair:    repair,
+		Drifts:    make([]models.Drift, 0),
+		StartedAt: started.Format(time.DateTime),
+	}
+
+	// links are read first: a product published while the market is paged
+	// has no link yet and its item is left to the next run, read the other
+	// way round the item would look missing and be republished
+	links, err := v.storage.PlatformLinks(ctx, models.PlatformVK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vk products from storage: %w", err)
+	}
+
+	items, err := v.marketItems(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market items: %w", err)
+	}
+
+	report.Local = len(links)
+	report.Remote = len(items)
+
+	linked := make(map[int]bool, len(links))
+
+	for _, link := range links {
+		linked[link.PlatformProductID] = true
+
+		// the item of a deleted product is removed by its delete job
+		if link.Deleted {
+			continue
+		}
+
+		item, ok := items[link.PlatformProductID]
+		if !ok || link.PlatformProductID == 0 || item.Availability == availabilityRemoved {
+			v.missing(ctx, report, link, repair)
+			continue
+		}
+
+		v.changed(ctx, report, link, item, repair)
+	}
+
+	for id, item := range items {
+		if linked[id] || item.Availability == availabilityRemoved {
+			continue
+		}
+
+		v.unknown(ctx, report, item, repair)
+	}
+
+	report.Duration = time.Since(started).Round(time.Millisecond).String()
+
+	v.log.Info("vk market reconciled",
+		"local", report.Local,
+		"remote", report.Remote,
+		"drifts", len(report.Drifts),
+		"repaired", report.Repaired,
+		"errors", len(report.Errors),
+	)
+
+	return report, nil
+}
+
+// missing handles a product linked to an item that is no longer on the market.
+func (v *Consumer) missing(ctx context.Context, report *models.ReconcileReport, link models.PlatformLink, repair bool) {
+	drift := models.Drift{
+		Kind:              models.DriftMissing,
+		ProductID:         link.ProductID,
+		PlatformProductID: link.PlatformProductID,
+	}
+
+	if !repair || v.reconcile.Missing == "" {
+		report.Drifts = append(report.Drifts, drift)
+		return
+	}
+
+	err := v.statusChanger.VkDeleted(link.ProductID)
+	if err == nil && v.reconcile.Missing == models.RepairRepublish {
+		err = v.publisher.WriteJob(ctx, link.ProductID, models.PlatformVK, models.JobActionAdd)
+	}
+
+	v.repaired(report, &drift, v.reconcile.Missing, err)
+}
+
+// changed reports the fields of the item edited on the market, pushing the
+// product again brings the stored state back.
+func (v *Consumer) changed(ctx context.Context, report *models.ReconcileReport, link models.PlatformLink, item object.MarketMarketItem, repair bool) {
+	p, err := v.storage.Product(ctx, link.ProductID)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("product %d: %s", link.ProductID, err.Error()))
+		return
+	}
+
+	var fields []string
+
+	// adopted items keep their name, products published before the names
+	// were saved have the one built from the product
+	name := link.Name
+	if name == "" {
+		name = marketName(p)
+	}
+
+	if strings.TrimSpace(item.Title) != strings.TrimSpace(name) {
+		fields = append(fields, "title")
+	}
+
+	if strings.TrimSpace(item.Description) != strings.TrimSpace(p.Description) {
+		fields = append(fields, "description")
+	}
+
+	if price, ok := itemPrice(item); ok && price != p.Price {
+		fields = append(fields, "price")
+	}
+
+	if !link.Loaded {
+		fields = append(fields, "loaded")
+	}
+
+	if len(fields) == 0 {
+		return
+	}
+
+	drift := models.Drift{
+		Kind:              models.DriftChanged,
+		ProductID:         link.ProductID,
+		PlatformProductID: link.PlatformProductID,
+		Title:             item.Title,
+		Fields:            fields,
+	}
+
+	if !repair || !v.reconcile.Push {
+		report.Drifts = append(report.Drifts, drift)
+		return
+	}
+
+	if !link.Loaded {
+		err = v.statusChanger.VkLoaded(link.ProductID, link.PlatformProductID)
+	}
+
+	if err == nil {
+		err = v.publisher.WriteJob(ctx, link.ProductID, models.PlatformVK, models.JobActionUpdate)
+	}
+
+	v.repaired(report, &drift, models.RepairPush, err)
+}
+
+// unknown handles an item of the market that no product is linked to.
+func (v *Consumer) unknown(ctx context.Context, report *models.ReconcileReport, item object.MarketMarketItem, repair bool) {
+	drift := models.Drift{
+		Kind:              models.DriftUnknown,
+		PlatformProductID: item.ID,
+		Title:             item.Title,
+	}
+
+	recent := time.Since(time.Unix(int64(item.Date), 0)) < adoptGrace
+
+	if !repair || !v.reconcile.Adopt || recent {
+		report.Drifts = append(report.Drifts, drift)
+		return
+	}
+
+	productID, err := v.adopt(ctx, item)
+
+	drift.ProductID = productID
+
+	v.repaired(report, &drift, models.RepairAdopt, err)
+}
+
+// adopt saves the item as a new product linked to it, the main picture is
+// downloaded like the one of a product added through the API. The item name
+// is saved as the published one: the stored title is lowercased and a
+// multiline description would give another name, pushing the product renames
+// the item. The market has no size and status, they're left empty and must be
+// set by PATCH before the product is replaced by PUT.
+func (v *Consumer) adopt(ctx context.Context, item object.MarketMarketItem) (int64, error) {
+	price, _ := itemPrice(item)
+
+	p := &models.Product{
+		Title:       item.Title,
+		Description: item.Description,
+		Price:       price,
+		VK: models.VK{
+			ToLoad:     true,
+			CategoryID: item.Category.ID,
+		},
+	}
+
+	for _, photo := range item.Photos {
+		url := photo.MaxSize().URL
+		if url == "" {
+			continue
+		}
+
+		if p.MainPictureURL == "" {
+			p.MainPictureURL = url
+			continue
+		}
+
+		p.PicturesURL = append(p.PicturesURL, url)
+	}
+
+	// a product without the link would be adopted again on the next run
+	productID, err := v.storage.AdoptVk(ctx, p, item.ID, item.Title)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save product: %w", err)
+	}
+
+	if p.MainPictureURL != "" {
+		if err := v.pictures.SavePictures(ctx, productID, p.Pictures()); err != nil {
+			v.log.Warn("failed to save pictures", "productID", productID, "err", err.Error())
+		}
+	}
+
+	return productID, nil
+}
+
+func (v *Consumer) repaired(report *models.ReconcileReport, drift *models.Drift, action string, err error) {
+	if err != nil {
+		v.log.Error("failed to repair vk drift", "kind", drift.Kind, "productID", drift.ProductID, "vkProductID", drift.PlatformProductID, "err", err.Error())
+
+		report.Errors = append(report.Errors, fmt.Sprintf("%s item %d: %s", drift.Kind, drift.PlatformProductID, err.Error()))
+		report.Drifts = append(report.Drifts, *drift)
+		return
+	}
+
+	drift.Repair = action
+
+	report.Repaired++
+	report.Drifts = append(report.Drifts, *drift)
+}
+
+// marketItems pages through market.get and returns the items of the group by ID.
+func (v *Consumer) marketItems(ctx context.Context) (map[int]object.MarketMarketItem, error) {
+	items := make(map[int]object.MarketMarketItem)
+
+	for offset := 0; ; offset += marketPageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		pars := params.NewMarketGetBuilder()
+
+		pars.OwnerID(-v.groupID)
+		pars.Count(marketPageSize)
+		pars.Offset(offset)
+		pars.Extended(true)
+
+		response, err := v.vk.MarketGet(api.Params(pars.Params))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range response.Items {
+			items[item.ID] = item
+		}
+
+		if len(response.Items) == 0 || offset+marketPageSize >= response.Count {
+			return items, nil
+		}
+	}
+}
+
+// itemPrice returns the price of the item in roubles, VK sends it in kopecks.
+func itemPrice(item object.MarketMarketItem) (int, bool) {
+	amount, err := strconv.Atoi(item.Price.Amount)
+	if err != nil {
+		return 0, false
+	}
+
+	return amount / 100, true
+}
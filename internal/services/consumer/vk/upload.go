@@ -0,0 +1,144 @@
+package vk
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"prodLoaderREST/internal/domain/models"
+	"sync"
+)
+
+// maxExtraPictures is how many pictures besides the main one VK takes.
+const maxExtraPictures = 4
+
+// UploadOptions limit the load on VK: Products are published at once, each
+// uploads up to Pictures of its extra pictures at once. Both must be positive,
+// Pictures is at most the number of extra pictures VK takes.
+type UploadOptions struct {
+	Products int
+	Pictures int
+}
+
+func (o UploadOptions) Validate() error {
+	if o.Products <= 0 {
+		return fmt.Errorf("vk products concurrency must be positive")
+	}
+
+	if o.Pictures <= 0 || o.Pictures > maxExtraPictures {
+		return fmt.Errorf("vk pictures concurrency must be from 1 to %d", maxExtraPictures)
+	}
+
+	return nil
+}
+
+// Concurrency is how many jobs are handled at once.
+func (v *Consumer) Concurrency() int {
+	return v.upload.Products
+}
+
+// loadPictures uploads the extra pictures of the product in parallel, the IDs
+// follow the order of picturesURL. A picture VK fails to take is skipped, a
+// picture that can't be read fails the whole upload.
+func (v *Consumer) loadPictures(ctx context.Context, log *slog.Logger, p *models.Product) ([]int, error) {
+	type picture struct {
+		n   int
+		url string
+	}
+
+	pictures := make([]picture, 0, maxExtraPictures)
+
+	for i, pic := range p.PicturesURL {
+		if len(pictures) == maxExtraPictures {
+			break
+		}
+
+		if pic == "" {
+			log.Warn("Picture URL is empty, skipping")
+			continue
+		}
+
+		// position 0 is the main picture
+		pictures = append(pictures, picture{n: i + 1, url: pic})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	ids := make([]int, len(pictures))
+	slots := make(chan struct{}, max(v.upload.Pictures, 1))
+
+	for i, pic := range pictures {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				fail(ctx.Err())
+				return
+			}
+			defer func() { <-slots }()
+
+			id, err := v.loadPicture(ctx, log, p.Id, pic.n, pic.url)
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			ids[i] = id
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	PicturesIDs := make([]int, 0, len(ids))
+
+	for _, id := range ids {
+		if id != 0 {
+			PicturesIDs = append(PicturesIDs, id)
+		}
+	}
+
+	return PicturesIDs, nil
+}
+
+// loadPicture uploads the n-th picture of the product, 0 is returned when VK
+// doesn't take it.
+func (v *Consumer) loadPicture(ctx context.Context, log *slog.Logger, productID int64, n int, picURL string) (int, error) {
+	body, err := v.openPicture(ctx, productID, n, picURL)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	respPhoto, err := v.vk.UploadMarketPhoto(v.groupID, false, body)
+	if err != nil {
+		log.Warn("failed to upload picture", "n", n, "err", err.Error())
+		return 0, nil
+	}
+
+	if len(respPhoto) == 0 {
+		return 0, nil
+	}
+
+	return respPhoto[0].ID, nil
+}
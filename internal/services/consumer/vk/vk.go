@@ -1,14 +1,17 @@
 package vk
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"log/slog"
-	"net/http"
 	"prodLoaderREST/internal/broker"
 	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/storage/pictureManager"
 	"strings"
+	"sync"
 
 	"github.com/SevereCloud/vksdk/api/params"
 	"github.com/SevereCloud/vksdk/v3/api"
@@ -20,187 +23,287 @@ var (
 
 type StatusChanger interface {
 	VkLoaded(productID int64, vkProductID int) error
+	SetVkName(productID int64, name string) error
 	VkDeleted(productID int64) error
+	VkProductID(productID int64) (int, error)
+}
+
+// Storage is used by reconciliation and import to compare the market with saved products.
+type Storage interface {
+	StatusChanger
+	Product(ctx context.Context, productID int64) (*models.Product, error)
+	PlatformLinks(ctx context.Context, platform string) ([]models.PlatformLink, error)
+	AdoptVk(ctx context.Context, product *models.Product, vkProductID int, name string) (int64, error)
+	SaveImportBatch(ctx context.Context, batch *models.ImportBatch) error
+	UpdateImportBatch(ctx context.Context, batch *models.ImportBatch) error
+}
+
+// Pictures keeps the pictures of products: the stored ones are uploaded to
+// the market and the pictures of imported items are stored.
+type Pictures interface {
+	SavePictures(ctx context.Context, productID int64, urls []string) error
+	Picture(ctx context.Context, productID int64, n int) ([]byte, error)
+}
+
+// Fetcher downloads the pictures that are not stored.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) ([]byte, error)
+}
+
+type Publisher interface {
+	WriteJob(ctx context.Context, productID int64, platform string, action string) error
 }
 
 type Consumer struct {
 	log           *slog.Logger
 	vk            *api.VK
 	statusChanger StatusChanger
+	storage       Storage
+	pictures      Pictures
+	fetcher       Fetcher
+	publisher     Publisher
 	groupID       int
+
+	upload      UploadOptions
+	reconcile   ReconcileOptions
+	reconcileMu sync.Mutex
+
+	// imports tracks the market imports running in the background
+	imports sync.WaitGroup
 }
 
-func New(log *slog.Logger, vk *api.VK, groupID int, StatusChanger StatusChanger) *Consumer {
+func New(log *slog.Logger, vk *api.VK, groupID int, storage Storage, pictures Pictures, fetcher Fetcher, publisher Publisher, upload UploadOptions, reconcile ReconcileOptions) *Consumer {
 	return &Consumer{
 		log:           log,
 		vk:            vk,
-		statusChanger: StatusChanger,
+		statusChanger: storage,
+		storage:       storage,
+		pictures:      pictures,
+		fetcher:       fetcher,
+		publisher:     publisher,
 		groupID:       groupID,
+		upload:        upload,
+		reconcile:     reconcile,
 	}
 }
 
-func (v *Consumer) GetClientName() string {
+func (v *Consumer) Name() string {
+	return models.PlatformVK
+}
+
+func (v *Consumer) GetClientName() (string, error) {
 
 	p := params.NewAccountGetInfoBuilder()
 
 	info, err := v.vk.AccountGetProfileInfo(api.Params(p.Params))
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
-	return info.FirstName + " " + info.LastName
+	return info.FirstName + " " + info.LastName, nil
 }
 
-func (v *Consumer) ListenLoad(products chan *models.Product) {
-	for p := range products {
+// Health checks that the token is valid.
+func (v *Consumer) Health(ctx context.Context) error {
+	name, err := v.GetClientName()
+	if err != nil {
+		return fmt.Errorf("failed to get vk profile: %w", err)
+	}
 
-		go func() {
+	v.log.Debug("vk client authorized", "name", name)
 
-			v.log.Debug("Received product", "product", p)
+	return nil
+}
 
-			if p == nil {
-				v.log.Error("Received nil product")
-				return
-			}
+// Update edits the item published to the market, pictures are uploaded again
+// only when the product has them.
+func (v *Consumer) Update(ctx context.Context, p *models.Product) error {
+	if p == nil {
+		return broker.Permanent(fmt.Errorf("nil product"))
+	}
 
-			log := v.log.With("Title", p.Title)
+	log := v.log.With("Title", p.Title)
 
-			MainPicResponse, err := v.loadMainPicture(p.MainPictureURL)
-			if err != nil {
-				log.Error("Failed to load main picture", "err", err.Error())
-				return
-			}
+	vkProductID, err := v.statusChanger.VkProductID(p.Id)
+	if err != nil {
+		return fmt.Errorf("failed to get vk product id: %w", err)
+	}
 
-			log.Debug("Main picture loaded")
+	if vkProductID == 0 {
+		return broker.Permanent(fmt.Errorf("product %d is not published to vk", p.Id))
+	}
 
-			PicturesIDs, err := v.loadPictures(log, p.PicturesURL)
-			if err != nil {
-				log.Error("Failed to load pictures", "err", err.Error())
-				return
-			}
+	pars := params.NewMarketEditBuilder()
 
-			parts := strings.Split(p.Description, "\n") //надо для корректного отображения названия товарв
+	pars.OwnerID(-v.groupID)
+	pars.ItemID(vkProductID)
+	pars.Name(marketName(p))
+	pars.Description(p.Description)
+	pars.Price(float64(p.Price))
 
-			pars := params.NewMarketAddBuilder()
+	if p.VK.CategoryID != 0 {
+		pars.CategoryID(p.VK.CategoryID)
+	}
 
-			pars.OwnerID(-v.groupID)
-			pars.Name(p.Title)
-			pars.MainPhotoID(MainPicResponse[0].ID)
-			pars.PhotoIDs(PicturesIDs)
-			pars.Description(p.Description)
-			pars.Price(float64(p.Price))
-			pars.CategoryID(p.VK.CategoryID)
+	if p.MainPictureURL != "" {
+		broker.ReportStage(ctx, models.JobStageUploadingPictures)
 
-			if len(parts) > 1 {
-				pars.Name(parts[0])
-			}
+		MainPicResponse, err := v.loadMainPicture(ctx, p)
+		if err != nil {
+			log.Error("Failed to load main picture", "err", err.Error())
+			return classify(fmt.Errorf("failed to load main picture: %w", err))
+		}
+
+		PicturesIDs, err := v.loadPictures(ctx, log, p)
+		if err != nil {
+			log.Error("Failed to load pictures", "err", err.Error())
+			return classify(fmt.Errorf("failed to load pictures: %w", err))
+		}
 
-			response, err := v.vk.MarketAdd(api.Params(pars.Params))
-			if err != nil {
-				log.Error("Failed to add product to market", "err", err.Error(), "respone", response)
-				return
-			}
+		pars.MainPhotoID(MainPicResponse[0].ID)
+		pars.PhotoIDs(PicturesIDs)
+	}
 
-			err = v.statusChanger.VkLoaded(p.Id, response.MarketItemID)
-			if err != nil {
-				log.Error("failed to change status", "error", err)
-			}
+	broker.ReportStage(ctx, models.JobStagePublishing)
 
-			log.Debug("Product added to market. ID Saved in storage")
-		}()
+	_, err = v.vk.MarketEdit(api.Params(pars.Params))
+	if err != nil {
+		log.Error("Failed to edit product on market", "err", err.Error())
+		return classify(fmt.Errorf("failed to edit product on market: %w", err))
 	}
+
+	log.Debug("Product edited on market", "vkProductID", vkProductID)
+
+	v.saveName(log, p)
+
+	return nil
 }
 
-func (v *Consumer) ListenDelete(products chan *broker.VkToDelete) {
+func (v *Consumer) Publish(ctx context.Context, p *models.Product) error {
 
-	for id := range products {
-		v.log.Debug("Recived product to delete", "productID", id.ProductID)
+	v.log.Debug("Received product", "product", p)
 
-		pars := params.NewMarketDeleteBuilder()
+	if p == nil {
+		v.log.Error("Received nil product")
+		return broker.Permanent(fmt.Errorf("nil product"))
+	}
 
-		pars.OwnerID(-v.groupID)
+	log := v.log.With("Title", p.Title)
 
-		pars.ItemID(id.VkProductID)
+	broker.ReportStage(ctx, models.JobStageUploadingPictures)
 
-		_, err := v.vk.MarketDelete(api.Params(pars.Params))
-		if err != nil {
-			v.log.Error("Failed to delete product from market", "productID", id, "err", err.Error())
+	MainPicResponse, err := v.loadMainPicture(ctx, p)
+	if err != nil {
+		log.Error("Failed to load main picture", "err", err.Error())
+		return classify(fmt.Errorf("failed to load main picture: %w", err))
+	}
 
-			return
-		}
+	log.Debug("Main picture loaded")
 
-		v.log.Debug("product deleted from VK", "productID", id.ProductID)
+	PicturesIDs, err := v.loadPictures(ctx, log, p)
+	if err != nil {
+		log.Error("Failed to load pictures", "err", err.Error())
+		return classify(fmt.Errorf("failed to load pictures: %w", err))
+	}
 
-		err = v.statusChanger.VkDeleted(int64(id.ProductID))
-		if err != nil {
-			v.log.Error("Failed to delete product from storage", "productID", id.ProductID, "err", err)
+	pars := params.NewMarketAddBuilder()
 
-		}
+	pars.OwnerID(-v.groupID)
+	pars.Name(marketName(p))
+	pars.MainPhotoID(MainPicResponse[0].ID)
+	pars.PhotoIDs(PicturesIDs)
+	pars.Description(p.Description)
+	pars.Price(float64(p.Price))
+	pars.CategoryID(p.VK.CategoryID)
 
+	broker.ReportStage(ctx, models.JobStagePublishing)
+
+	response, err := v.vk.MarketAdd(api.Params(pars.Params))
+	if err != nil {
+		log.Error("Failed to add product to market", "err", err.Error(), "respone", response)
+		return classify(fmt.Errorf("failed to add product to market: %w", err))
+	}
+
+	err = v.statusChanger.VkLoaded(p.Id, response.MarketItemID)
+	if err != nil {
+		log.Error("failed to change status", "error", err)
+		// the item is already on the market, retrying would add a duplicate
+		return broker.Permanent(fmt.Errorf("failed to change status: %w", err))
 	}
 
+	v.saveName(log, p)
+
+	log.Debug("Product added to market. ID Saved in storage")
+
+	return nil
 }
 
-func (v *Consumer) loadPictures(log *slog.Logger, picURLs []string) ([]int, error) {
+func (v *Consumer) Unpublish(ctx context.Context, productID int64, vkProductID int) error {
+	v.log.Debug("Recived product to delete", "productID", productID)
 
-	PicturesIDs := make([]int, 0)
+	pars := params.NewMarketDeleteBuilder()
 
-	for _, pic := range picURLs {
-		if len(PicturesIDs) == 4 {
-			break
-		}
+	pars.OwnerID(-v.groupID)
 
-		if pic == "" {
-			log.Warn("Picture URL is empty, skipping")
-			continue
-		}
+	pars.ItemID(vkProductID)
 
-		resp, err := http.Get(pic)
-		if err != nil {
-			return nil, err
-		}
+	_, err := v.vk.MarketDelete(api.Params(pars.Params))
+	if err != nil {
+		v.log.Error("Failed to delete product from market", "productID", productID, "err", err.Error())
 
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unexepeted status: %s", resp.Status)
-		}
+		return classify(fmt.Errorf("failed to delete product from market: %w", err))
+	}
 
-		respPhoto, err := v.vk.UploadMarketPhoto(v.groupID, false, resp.Body)
-		if err != nil {
-			v.log.Warn("failed to upload picture", "err", err.Error())
-		}
+	v.log.Debug("product deleted from VK", "productID", productID)
 
-		if len(respPhoto) >= 1 {
-			PicturesIDs = append(PicturesIDs, respPhoto[0].ID)
-		}
+	err = v.statusChanger.VkDeleted(productID)
+	if err != nil {
+		v.log.Error("Failed to delete product from storage", "productID", productID, "err", err)
+
+		return fmt.Errorf("failed to change status: %w", err)
 	}
 
-	return PicturesIDs, nil
+	return nil
 }
 
-func (v *Consumer) loadMainPicture(picURL string) (api.PhotosSaveMarketPhotoResponse, error) {
+// saveName keeps the name the product is published with for reconciliation,
+// without it the name is taken from the product, so the error is only logged.
+func (v *Consumer) saveName(log *slog.Logger, p *models.Product) {
+	if err := v.statusChanger.SetVkName(p.Id, marketName(p)); err != nil {
+		log.Warn("failed to save market name", "err", err.Error())
+	}
+}
+
+// marketName is the first line of a multiline description, the title otherwise.
+func marketName(p *models.Product) string {
+	parts := strings.Split(p.Description, "\n") //надо для корректного отображения названия товарв
+
+	if len(parts) > 1 {
+		return parts[0]
+	}
 
-	if picURL == "" {
-		return nil, fmt.Errorf("main picture URL is empty")
+	return p.Title
+}
+
+func (v *Consumer) loadMainPicture(ctx context.Context, p *models.Product) (api.PhotosSaveMarketPhotoResponse, error) {
+
+	if p.MainPictureURL == "" {
+		return nil, broker.Permanent(fmt.Errorf("main picture URL is empty"))
 	}
 
-	resp, err := http.Get(picURL)
+	body, err := v.openPicture(ctx, p.Id, 0, p.MainPictureURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch image: %w", err)
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %s", resp.Status)
-	}
+	defer body.Close()
 
-	vkMainPicResp, err := v.vk.UploadMarketPhoto(v.groupID, true, resp.Body)
+	vkMainPicResp, err := v.vk.UploadMarketPhoto(v.groupID, true, body)
 	if err != nil {
-		return nil, fmt.Errorf("can't load MainPhoto to VK: %s", err.Error())
+		return nil, fmt.Errorf("can't load MainPhoto to VK: %w", err)
 	}
 
-	v.log.Debug("Main picture loaded", "url", picURL, "content-length", resp.ContentLength)
+	v.log.Debug("Main picture loaded", "url", p.MainPictureURL)
 
 	if len(vkMainPicResp) == 0 {
 		return nil, fmt.Errorf("no response from VK when uploading main picture")
@@ -208,3 +311,23 @@ func (v *Consumer) loadMainPicture(picURL string) (api.PhotosSaveMarketPhotoResp
 
 	return vkMainPicResp, nil
 }
+
+// openPicture returns the n-th picture of the product from the picture store,
+// it's downloaded from the URL only when it isn't stored.
+func (v *Consumer) openPicture(ctx context.Context, productID int64, n int, picURL string) (io.ReadCloser, error) {
+	data, err := v.pictures.Picture(ctx, productID, n)
+	if err == nil {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	if !errors.Is(err, pictureManager.ErrNotFound) {
+		v.log.Warn("failed to read stored picture", "productID", productID, "n", n, "err", err.Error())
+	}
+
+	data, err = v.fetcher.Fetch(ctx, picURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,69 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"io"
+	"prodLoaderREST/internal/domain/models"
+	"strconv"
+	"strings"
+)
+
+// csvHeader uses the column names of the import, so the file can be imported back.
+var csvHeader = []string{
+	"id", "title", "description", "size", "status", "price",
+	"mainPictureURL", "picturesURL",
+	"vkToLoad", "vkCategoryID", "vkLoaded", "vkProductID",
+	"ucozToLoad", "ucozCategoryID", "ucozLoaded", "ucozProductID",
+	"avitoToLoad", "avitoLoaded", "avitoProductID",
+	"createdAt",
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) begin() error {
+	return e.w.Write(csvHeader)
+}
+
+func (e *csvEncoder) write(p *models.Product) error {
+	err := e.w.Write([]string{
+		strconv.FormatInt(p.Id, 10),
+		p.Title,
+		p.Description,
+		p.Size,
+		p.Status,
+		strconv.Itoa(p.Price),
+		p.MainPictureURL,
+		strings.Join(p.PicturesURL, "|"),
+		strconv.FormatBool(p.VK.ToLoad),
+		strconv.Itoa(p.VK.CategoryID),
+		strconv.FormatBool(p.VK.Loaded),
+		strconv.Itoa(p.VK.ProductID),
+		strconv.FormatBool(p.Ucoz.ToLoad),
+		strconv.Itoa(p.Ucoz.CategoryID),
+		strconv.FormatBool(p.Ucoz.Loaded),
+		strconv.Itoa(p.Ucoz.ProductID),
+		strconv.FormatBool(p.Avito.ToLoad),
+		strconv.FormatBool(p.Avito.Loaded),
+		strconv.Itoa(p.Avito.ProductID),
+		p.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end() error {
+	e.w.Flush()
+
+	return e.w.Error()
+}
@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"prodLoaderREST/internal/domain/models"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatYML  = "yml"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+type Storage interface {
+	EachProduct(ctx context.Context, fn func(p *models.Product) error) error
+}
+
+// encoder writes products one by one, begin and end frame the document.
+type encoder interface {
+	begin() error
+	write(p *models.Product) error
+	end() error
+}
+
+// YMLOptions describe the shop in the Yandex Market feed.
+type YMLOptions struct {
+	ShopName string
+	Company  string
+	URL      string
+	Currency string
+	Category string
+}
+
+type Exporter struct {
+	storage Storage
+	yml     YMLOptions
+}
+
+func New(storage Storage, yml YMLOptions) *Exporter {
+	return &Exporter{
+		storage: storage,
+		yml:     yml,
+	}
+}
+
+// ContentType returns the MIME type of the format, ErrUnknownFormat if it's not supported.
+func ContentType(format string) (string, error) {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8", nil
+	case FormatJSON:
+		return "application/json; charset=utf-8", nil
+	case FormatYML:
+		return "application/xml; charset=utf-8", nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// Export writes every product to w as soon as it's loaded from storage.
+func (e *Exporter) Export(ctx context.Context, format string, w io.Writer) error {
+	var enc encoder
+
+	switch format {
+	case FormatCSV:
+		enc = newCSVEncoder(w)
+	case FormatJSON:
+		enc = newJSONEncoder(w)
+	case FormatYML:
+		enc = newYMLEncoder(w, e.yml)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	if err := enc.begin(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	err := e.storage.EachProduct(ctx, func(p *models.Product) error {
+		if err := enc.write(p); err != nil {
+			return fmt.Errorf("failed to write product %d: %w", p.Id, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := enc.end(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+	"prodLoaderREST/internal/domain/models"
+)
+
+// jsonEncoder writes a JSON array, one product per line.
+type jsonEncoder struct {
+	w     io.Writer
+	first bool
+}
+
+func newJSONEncoder(w io.Writer) *jsonEncoder {
+	return &jsonEncoder{w: w, first: true}
+}
+
+func (e *jsonEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonEncoder) write(p *models.Product) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if e.first {
+		sep = "\n"
+		e.first = false
+	}
+
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(data)
+
+	return err
+}
+
+func (e *jsonEncoder) end() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
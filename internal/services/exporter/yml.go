@@ -0,0 +1,175 @@
+package exporter
+
+import (
+	"encoding/xml"
+	"io"
+	"prodLoaderREST/internal/domain/models"
+	"strconv"
+	"time"
+)
+
+const (
+	ymlDateLayout  = "2006-01-02T15:04:05-07:00"
+	ymlCategoryID  = "1"
+	ymlMaxPictures = 10
+)
+
+type ymlCurrency struct {
+	XMLName xml.Name `xml:"currency"`
+	ID      string   `xml:"id,attr"`
+	Rate    string   `xml:"rate,attr"`
+}
+
+type ymlCategory struct {
+	XMLName xml.Name `xml:"category"`
+	ID      string   `xml:"id,attr"`
+	Name    string   `xml:",chardata"`
+}
+
+type ymlOffer struct {
+	XMLName     xml.Name   `xml:"offer"`
+	ID          string     `xml:"id,attr"`
+	Available   bool       `xml:"available,attr"`
+	Name        string     `xml:"name"`
+	Price       int        `xml:"price"`
+	CurrencyID  string     `xml:"currencyId"`
+	CategoryID  string     `xml:"categoryId"`
+	Pictures    []string   `xml:"picture"`
+	Description cdata      `xml:"description"`
+	Params      []ymlParam `xml:"param"`
+}
+
+type ymlParam struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type cdata struct {
+	Text string `xml:",cdata"`
+}
+
+// ymlEncoder writes the Yandex Market YML catalogue. The shop has a single
+// category, offers are encoded one by one between the opening and closing tags.
+type ymlEncoder struct {
+	w    io.Writer
+	enc  *xml.Encoder
+	opts YMLOptions
+}
+
+func newYMLEncoder(w io.Writer, opts YMLOptions) *ymlEncoder {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	return &ymlEncoder{w: w, enc: enc, opts: opts}
+}
+
+func (e *ymlEncoder) begin() error {
+	if _, err := io.WriteString(e.w, xml.Header); err != nil {
+		return err
+	}
+
+	catalog := xml.StartElement{
+		Name: xml.Name{Local: "yml_catalog"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "date"}, Value: time.Now().Format(ymlDateLayout)}},
+	}
+
+	for _, t := range []xml.Token{catalog, start("shop")} {
+		if err := e.enc.EncodeToken(t); err != nil {
+			return err
+		}
+	}
+
+	fields := []struct{ name, value string }{
+		{"name", e.opts.ShopName},
+		{"company", e.opts.Company},
+		{"url", e.opts.URL},
+	}
+
+	for _, f := range fields {
+		if err := e.enc.EncodeElement(f.value, start(f.name)); err != nil {
+			return err
+		}
+	}
+
+	currencies := struct {
+		XMLName  xml.Name    `xml:"currencies"`
+		Currency ymlCurrency `xml:"currency"`
+	}{Currency: ymlCurrency{ID: e.opts.Currency, Rate: "1"}}
+
+	if err := e.enc.Encode(currencies); err != nil {
+		return err
+	}
+
+	categories := struct {
+		XMLName  xml.Name    `xml:"categories"`
+		Category ymlCategory `xml:"category"`
+	}{Category: ymlCategory{ID: ymlCategoryID, Name: e.opts.Category}}
+
+	if err := e.enc.Encode(categories); err != nil {
+		return err
+	}
+
+	if err := e.enc.EncodeToken(start("offers")); err != nil {
+		return err
+	}
+
+	return e.enc.Flush()
+}
+
+func (e *ymlEncoder) write(p *models.Product) error {
+	offer := ymlOffer{
+		ID:          strconv.FormatInt(p.Id, 10),
+		Available:   true,
+		Name:        p.Title,
+		Price:       p.Price,
+		CurrencyID:  e.opts.Currency,
+		CategoryID:  ymlCategoryID,
+		Description: cdata{Text: p.Description},
+	}
+
+	for _, url := range append([]string{p.MainPictureURL}, p.PicturesURL...) {
+		if len(offer.Pictures) == ymlMaxPictures {
+			break
+		}
+
+		if url == "" {
+			continue
+		}
+
+		offer.Pictures = append(offer.Pictures, url)
+	}
+
+	if p.Size != "" {
+		offer.Params = append(offer.Params, ymlParam{Name: "Размер", Value: p.Size})
+	}
+
+	if p.Status != "" {
+		offer.Params = append(offer.Params, ymlParam{Name: "Состояние", Value: p.Status})
+	}
+
+	if err := e.enc.Encode(offer); err != nil {
+		return err
+	}
+
+	return e.enc.Flush()
+}
+
+func (e *ymlEncoder) end() error {
+	for _, name := range []string{"offers", "shop", "yml_catalog"} {
+		if err := e.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+
+	if err := e.enc.Close(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(e.w, "\n")
+
+	return err
+}
+
+func start(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"prodLoaderREST/internal/domain/models"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type Exchanger interface {
+	WriteAdd(ctx context.Context, product *models.Product) ([]*models.Job, error)
+}
+
+type Storage interface {
+	SaveImportBatch(ctx context.Context, batch *models.ImportBatch) error
+	UpdateImportBatch(ctx context.Context, batch *models.ImportBatch) error
+}
+
+type Importer struct {
+	log       *slog.Logger
+	exchanger Exchanger
+	storage   Storage
+	validate  *validator.Validate
+
+	// wg tracks the batches added in the background
+	wg sync.WaitGroup
+}
+
+func New(log *slog.Logger, exchanger Exchanger, storage Storage) *Importer {
+	return &Importer{
+		log:       log,
+		exchanger: exchanger,
+		storage:   storage,
+		validate:  validator.New(),
+	}
+}
+
+// Import parses and validates the file. A dry run only reports the rows, otherwise
+// the batch is saved and its valid rows are added in the background, the batch
+// is updated once every row is added.
+func (i *Importer) Import(ctx context.Context, filename string, format string, r io.Reader, dryRun bool) (*models.ImportBatch, error) {
+	rows, err := parse(format, r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+
+	batch := &models.ImportBatch{
+		Filename: filename,
+		Format:   format,
+		Status:   models.ImportStatusProcessing,
+		Total:    len(rows),
+		Rows:     make([]models.ImportRow, 0, len(rows)),
+	}
+
+	var valid []int
+
+	for n, r := range rows {
+		report := models.ImportRow{Row: r.line, Title: r.product.Title}
+
+		if err := i.check(r); err != nil {
+			report.Errors = messages(err)
+			batch.Failed++
+		} else {
+			valid = append(valid, n)
+			batch.Valid++
+		}
+
+		batch.Rows = append(batch.Rows, report)
+	}
+
+	if dryRun {
+		batch.Status = models.ImportStatusDryRun
+		return batch, nil
+	}
+
+	if len(valid) == 0 {
+		batch.Status = models.ImportStatusDone
+	}
+
+	if err := i.storage.SaveImportBatch(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to save import batch: %w", err)
+	}
+
+	if len(valid) == 0 {
+		return batch, nil
+	}
+
+	// the copy is owned by the goroutine, the caller gets the batch as saved
+	result := *batch
+	result.Rows = append([]models.ImportRow(nil), batch.Rows...)
+
+	i.wg.Add(1)
+
+	go func() {
+		defer i.wg.Done()
+
+		i.add(context.WithoutCancel(ctx), batch, rows, valid)
+	}()
+
+	return &result, nil
+}
+
+// Wait blocks until the batches being added are done or ctx is cancelled,
+// a batch left unfinished is marked interrupted on the next start.
+func (i *Importer) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		i.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *Importer) add(ctx context.Context, batch *models.ImportBatch, rows []row, valid []int) {
+	log := i.log.With("batchID", batch.ID)
+
+	log.Info("import started", "rows", len(valid))
+
+	for _, n := range valid {
+		product := rows[n].product
+
+		if _, err := i.exchanger.WriteAdd(ctx, product); err != nil {
+			log.Error("failed to add imported product", "row", rows[n].line, "err", err.Error())
+
+			batch.Rows[n].Errors = []string{err.Error()}
+			batch.Failed++
+			continue
+		}
+
+		batch.Rows[n].ProductID = product.Id
+		batch.Imported++
+	}
+
+	batch.Status = models.ImportStatusDone
+
+	if err := i.storage.UpdateImportBatch(ctx, batch); err != nil {
+		log.Error("failed to save import batch", "err", err.Error())
+		return
+	}
+
+	log.Info("import finished", "imported", batch.Imported, "failed", batch.Failed)
+}
+
+func (i *Importer) check(r row) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return i.validate.Struct(r.product)
+}
+
+// messages splits the error of the row into readable messages.
+func messages(err error) []string {
+	var validationErrs validator.ValidationErrors
+
+	if errors.As(err, &validationErrs) {
+		list := make([]string, 0, len(validationErrs))
+
+		for _, e := range validationErrs {
+			switch e.ActualTag() {
+			case "required", "required_without":
+				list = append(list, fmt.Sprintf("field %s is missed", e.Field()))
+			default:
+				list = append(list, fmt.Sprintf("field %s is not valid", e.Field()))
+			}
+		}
+
+		return list
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var list []string
+
+		for _, e := range joined.Unwrap() {
+			list = append(list, e.Error())
+		}
+
+		return list
+	}
+
+	return []string{err.Error()}
+}
@@ -0,0 +1,283 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"prodLoaderREST/internal/domain/models"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const maxRows = 10000
+
+var (
+	ErrInvalidFile   = errors.New("invalid import file")
+	ErrUnknownFormat = errors.New("unknown import format")
+	ErrNoHeader      = errors.New("file has no header row")
+	ErrTooManyRows   = fmt.Errorf("file has more than %d rows", maxRows)
+)
+
+// row is a parsed line of the file, err is set when it can't be mapped to a product.
+type row struct {
+	line    int
+	product *models.Product
+	err     error
+}
+
+// FormatFromFilename guesses the format by the file extension.
+func FormatFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return models.ImportFormatCSV
+	case ".xlsx":
+		return models.ImportFormatXLSX
+	case ".ndjson", ".jsonl":
+		return models.ImportFormatNDJSON
+	}
+
+	return ""
+}
+
+func parse(format string, r io.Reader) ([]row, error) {
+	switch format {
+	case models.ImportFormatCSV:
+		return parseCSV(r)
+	case models.ImportFormatXLSX:
+		return parseXLSX(r)
+	case models.ImportFormatNDJSON:
+		return parseNDJSON(r)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// parseCSV reads a file with a header row, the delimiter is a comma or a
+// semicolon as in spreadsheet exports.
+func parseCSV(r io.Reader) ([]row, error) {
+	br := bufio.NewReader(r)
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	firstLine, _ := br.Peek(4096)
+	if line, _, _ := bytes.Cut(firstLine, []byte("\n")); bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %w", err)
+	}
+
+	return tableRows(records)
+}
+
+// parseXLSX reads the first sheet of the workbook, the first row is the header.
+func parseXLSX(r io.Reader) ([]row, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xlsx: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, ErrNoHeader
+	}
+
+	records, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xlsx: %w", err)
+	}
+
+	return tableRows(records)
+}
+
+func tableRows(records [][]string) ([]row, error) {
+	if len(records) == 0 {
+		return nil, ErrNoHeader
+	}
+
+	if len(records)-1 > maxRows {
+		return nil, ErrTooManyRows
+	}
+
+	header := records[0]
+
+	for i, name := range header {
+		if _, ok := columns[normalize(name)]; !ok && !ignored[normalize(name)] && strings.TrimSpace(name) != "" {
+			return nil, fmt.Errorf("unknown column %d: %s", i+1, name)
+		}
+	}
+
+	var rows []row
+
+	for i, record := range records[1:] {
+		if isEmpty(record) {
+			continue
+		}
+
+		r := row{line: i + 2, product: &models.Product{}}
+
+		var errs []error
+
+		for j, value := range record {
+			if j >= len(header) {
+				break
+			}
+
+			set, ok := columns[normalize(header[j])]
+			if !ok {
+				continue
+			}
+
+			if err := set(r.product, strings.TrimSpace(value)); err != nil {
+				errs = append(errs, fmt.Errorf("column %s: %w", header[j], err))
+			}
+		}
+
+		r.err = errors.Join(errs...)
+
+		rows = append(rows, r)
+	}
+
+	return rows, nil
+}
+
+func parseNDJSON(r io.Reader) ([]row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []row
+
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		if len(rows) == maxRows {
+			return nil, ErrTooManyRows
+		}
+
+		r := row{line: line, product: &models.Product{}}
+
+		if err := json.Unmarshal(data, r.product); err != nil {
+			r.err = fmt.Errorf("invalid json: %w", err)
+		}
+
+		rows = append(rows, r)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ndjson: %w", err)
+	}
+
+	return rows, nil
+}
+
+// columns maps normalized header names to product fields, so "mainPictureURL",
+// "main_picture_url" and "Main picture URL" are the same column.
+var columns = map[string]func(p *models.Product, value string) error{
+	"title":       func(p *models.Product, v string) error { p.Title = v; return nil },
+	"description": func(p *models.Product, v string) error { p.Description = v; return nil },
+	"size":        func(p *models.Product, v string) error { p.Size = v; return nil },
+	"status":      func(p *models.Product, v string) error { p.Status = v; return nil },
+	"price":       func(p *models.Product, v string) error { return parseInt(v, &p.Price) },
+	"mainpictureurl": func(p *models.Product, v string) error {
+		p.MainPictureURL = v
+		return nil
+	},
+	"picturesurl": func(p *models.Product, v string) error {
+		p.PicturesURL = splitURLs(v)
+		return nil
+	},
+	"vktoload":       func(p *models.Product, v string) error { return parseBool(v, &p.VK.ToLoad) },
+	"vkcategoryid":   func(p *models.Product, v string) error { return parseInt(v, &p.VK.CategoryID) },
+	"ucoztoload":     func(p *models.Product, v string) error { return parseBool(v, &p.Ucoz.ToLoad) },
+	"ucozcategoryid": func(p *models.Product, v string) error { return parseInt(v, &p.Ucoz.CategoryID) },
+	"avitotoload":    func(p *models.Product, v string) error { return parseBool(v, &p.Avito.ToLoad) },
+}
+
+// ignored are the columns of the export set by storage and the platforms,
+// they are skipped so an exported file can be imported back.
+var ignored = map[string]bool{
+	"id":             true,
+	"vkloaded":       true,
+	"vkproductid":    true,
+	"ucozloaded":     true,
+	"ucozproductid":  true,
+	"avitoloaded":    true,
+	"avitoproductid": true,
+	"createdat":      true,
+}
+
+func normalize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+func parseInt(value string, dst *int) error {
+	if value == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+
+	*dst = n
+
+	return nil
+}
+
+func parseBool(value string, dst *bool) error {
+	if value == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(strings.ToLower(value))
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", value)
+	}
+
+	*dst = b
+
+	return nil
+}
+
+// splitURLs splits a cell with several picture URLs separated by spaces, "|" or ";".
+func splitURLs(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == '|' || r == ';' || unicode.IsSpace(r)
+	})
+}
+
+func isEmpty(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+
+	return true
+}
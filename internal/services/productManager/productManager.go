@@ -1,31 +1,140 @@
 package productManager
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"prodLoaderREST/internal/broker"
-	"prodLoaderREST/internal/services/consumer/vk"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/services/consumer"
 	"prodLoaderREST/internal/storage"
+	"sync"
 )
 
 type Manager struct {
-	VK *vk.Consumer
-	//Ucoz ucoz.Consumer
-	// Avito avito.Consumer
-	log     *slog.Logger
-	storage storage.Storage
-	broker  *broker.Exchanger
+	registry *Registry
+	log      *slog.Logger
+	storage  storage.Storage
+	broker   *broker.Exchanger
+
+	// wg tracks the workers and the background work of the marketplaces
+	wg sync.WaitGroup
 }
 
-func New(log *slog.Logger, VK *vk.Consumer, broker *broker.Exchanger, storage storage.Storage) *Manager {
+func New(log *slog.Logger, broker *broker.Exchanger, storage storage.Storage) *Manager {
 	return &Manager{
-		log:     log,
-		VK:      VK,
-		storage: storage,
-		broker:  broker,
+		registry: NewRegistry(),
+		log:      log,
+		storage:  storage,
+		broker:   broker,
+	}
+}
+
+// Register enables the marketplace, it must be called before Listen.
+func (m *Manager) Register(marketplace consumer.Marketplace) error {
+	return m.registry.Register(marketplace)
+}
+
+func (m *Manager) Marketplace(name string) (consumer.Marketplace, bool) {
+	return m.registry.Get(name)
+}
+
+// defaultWorkers is the pool of a marketplace that doesn't limit its jobs itself.
+const defaultWorkers = 4
+
+// Listen starts every registered marketplace: a pool of workers for its jobs
+// from the broker and its background work.
+func (m *Manager) Listen(ctx context.Context) {
+	for _, marketplace := range m.registry.All() {
+		workers := defaultWorkers
+		if limited, ok := marketplace.(consumer.Limited); ok && limited.Concurrency() > 0 {
+			workers = limited.Concurrency()
+		}
+
+		m.log.Info("marketplace enabled", "name", marketplace.Name(), "workers", workers)
+
+		// the queue holds no more jobs than the workers take, the rest stay in the outbox
+		jobs := m.broker.Subscribe(marketplace.Name(), workers)
+
+		for range workers {
+			m.wg.Add(1)
+			go func() {
+				defer m.wg.Done()
+				m.work(ctx, marketplace, jobs)
+			}()
+		}
+
+		if runner, ok := marketplace.(consumer.Runner); ok {
+			m.wg.Add(1)
+			go func() {
+				defer m.wg.Done()
+				runner.Run(ctx)
+			}()
+		}
+	}
+}
+
+// Wait blocks until the goroutines started by Listen return after its ctx is
+// cancelled, or until ctx is cancelled.
+func (m *Manager) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health checks every registered marketplace, the value is nil for healthy ones.
+func (m *Manager) Health(ctx context.Context) map[string]error {
+	result := make(map[string]error)
+
+	for _, marketplace := range m.registry.All() {
+		result[marketplace.Name()] = marketplace.Health(ctx)
+	}
+
+	return result
+}
+
+// work handles jobs one by one, every marketplace runs a pool of workers.
+func (m *Manager) work(ctx context.Context, marketplace consumer.Marketplace, jobs <-chan *broker.Job) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-jobs:
+			job.Done(m.handle(ctx, marketplace, job))
+		}
 	}
 }
 
-func (m *Manager) Listen() {
-	go m.VK.ListenLoad(broker.VKaddProductChannel)
-	go m.VK.ListenDelete(broker.VKdeleteProductChannel)
+func (m *Manager) handle(ctx context.Context, marketplace consumer.Marketplace, job *broker.Job) error {
+	var err error
+
+	ctx = broker.WithJob(ctx, job)
+
+	switch {
+	case job.Action == models.JobActionAdd && job.Product != nil:
+		err = marketplace.Publish(ctx, job.Product)
+	case job.Action == models.JobActionUpdate && job.Product != nil:
+		err = marketplace.Update(ctx, job.Product)
+	case job.Action == models.JobActionDelete && job.Delete != nil:
+		err = marketplace.Unpublish(ctx, int64(job.Delete.ProductID), job.Delete.PlatformProductID)
+	default:
+		err = broker.Permanent(fmt.Errorf("invalid %s job %d", job.Action, job.ID))
+	}
+
+	if errors.Is(err, consumer.ErrNotSupported) {
+		return broker.Permanent(err)
+	}
+
+	return err
 }
@@ -0,0 +1,55 @@
+package productManager
+
+import (
+	"fmt"
+	"prodLoaderREST/internal/services/consumer"
+	"sync"
+)
+
+type Registry struct {
+	mu           sync.RWMutex
+	marketplaces map[string]consumer.Marketplace
+	names        []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		marketplaces: make(map[string]consumer.Marketplace),
+	}
+}
+
+func (r *Registry) Register(m consumer.Marketplace) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.marketplaces[m.Name()]; exists {
+		return fmt.Errorf("marketplace %s is already registered", m.Name())
+	}
+
+	r.marketplaces[m.Name()] = m
+	r.names = append(r.names, m.Name())
+
+	return nil
+}
+
+func (r *Registry) Get(name string) (consumer.Marketplace, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.marketplaces[name]
+
+	return m, ok
+}
+
+// All returns marketplaces in the order of registration.
+func (r *Registry) All() []consumer.Marketplace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]consumer.Marketplace, 0, len(r.names))
+	for _, name := range r.names {
+		list = append(list, r.marketplaces[name])
+	}
+
+	return list
+}
@@ -0,0 +1,506 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"prodLoaderREST/internal/domain/models"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// retryDelay is the pause after a failed getUpdates.
+const retryDelay = 5 * time.Second
+
+// Steps of a draft: the marketplaces are picked first, then the categories
+// of the ones that have them.
+const (
+	stepPlatforms    = "platforms"
+	stepVKCategory   = "vk_category"
+	stepUcozCategory = "ucoz_category"
+)
+
+// Data of the inline buttons.
+const (
+	dataNext         = "next"
+	dataCancel       = "cancel"
+	dataPlatform     = "platform:"
+	dataVKCategory   = "vk_category:"
+	dataUcozCategory = "ucoz_category:"
+)
+
+// FilesPath is the path of the pictures under Options.FilesURL, the file ID
+// and ".jpg" follow it.
+const FilesPath = "/telegram/files/"
+
+var allPlatforms = []string{models.PlatformVK, models.PlatformUcoz, models.PlatformAvito}
+
+var platformNames = map[string]string{
+	models.PlatformVK:    "VK",
+	models.PlatformUcoz:  "uCoz",
+	models.PlatformAvito: "Avito",
+}
+
+type Exchanger interface {
+	WriteAdd(ctx context.Context, product *models.Product) ([]*models.Job, error)
+}
+
+// Category is a marketplace category the staff can pick.
+type Category struct {
+	ID   int
+	Name string
+}
+
+// ParseCategories reads categories written as "id:name".
+func ParseCategories(list []string) ([]Category, error) {
+	categories := make([]Category, 0, len(list))
+
+	for _, item := range list {
+		id, name, ok := strings.Cut(item, ":")
+
+		categoryID, err := strconv.Atoi(strings.TrimSpace(id))
+		if !ok || err != nil || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid category %q, want id:name", item)
+		}
+
+		categories = append(categories, Category{ID: categoryID, Name: strings.TrimSpace(name)})
+	}
+
+	return categories, nil
+}
+
+// Options of the bot. FilesURL is the public root of the API the pictures
+// are served from, VK and uCoz download them from there. Without categories
+// the step is skipped and the category is 0.
+type Options struct {
+	AllowedUsers   []int64
+	PollTimeout    time.Duration
+	FilesURL       string
+	VKCategories   []Category
+	UcozCategories []Category
+}
+
+func (o Options) Validate() error {
+	if len(o.AllowedUsers) == 0 {
+		return fmt.Errorf("no telegram users are allowed")
+	}
+
+	parsed, err := url.Parse(o.FilesURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid telegram files URL: %s", o.FilesURL)
+	}
+
+	return nil
+}
+
+// draft is a product being added in a chat.
+type draft struct {
+	product   *models.Product
+	photos    []string
+	platforms []string
+	step      string
+	// messageID is the message with the keyboard, it's edited on every step
+	messageID int
+}
+
+// Bot adds products sent by the staff: photos with a caption, then the
+// marketplaces and categories picked with inline keyboards.
+type Bot struct {
+	log       *slog.Logger
+	client    *Client
+	exchanger Exchanger
+	opts      Options
+	validate  *validator.Validate
+
+	// drafts by chat, updates are handled one by one so they are not locked
+	drafts map[int64]*draft
+}
+
+func New(log *slog.Logger, client *Client, exchanger Exchanger, opts Options) *Bot {
+	return &Bot{
+		log:       log.With("service", "telegram"),
+		client:    client,
+		exchanger: exchanger,
+		opts:      opts,
+		validate:  validator.New(),
+		drafts:    make(map[int64]*draft),
+	}
+}
+
+// Run long polls the Bot API and handles updates until ctx is cancelled.
+func (b *Bot) Run(ctx context.Context) {
+	b.log.Info("telegram bot started")
+
+	var offset int64
+
+	for {
+		updates, err := b.client.GetUpdates(ctx, offset, b.opts.PollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				b.log.Info("telegram bot stopped")
+				return
+			}
+
+			b.log.Error("failed to get telegram updates", "err", err.Error())
+
+			select {
+			case <-ctx.Done():
+				b.log.Info("telegram bot stopped")
+				return
+			case <-time.After(retryDelay):
+			}
+
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+
+			switch {
+			case update.Message != nil:
+				b.handleMessage(ctx, update.Message)
+			case update.CallbackQuery != nil:
+				b.handleCallback(ctx, update.CallbackQuery)
+			}
+		}
+	}
+}
+
+func (b *Bot) allowed(user *User) bool {
+	return user != nil && slices.Contains(b.opts.AllowedUsers, user.ID)
+}
+
+func (b *Bot) handleMessage(ctx context.Context, msg *Message) {
+	if !b.allowed(msg.From) {
+		b.log.Warn("message from unknown telegram user", "chatID", msg.Chat.ID)
+		b.reply(ctx, msg.Chat.ID, "Бот только для сотрудников магазина.")
+		return
+	}
+
+	chatID := msg.Chat.ID
+
+	switch strings.TrimSpace(msg.Text) {
+	case "/start", "/help":
+		b.reply(ctx, chatID, help)
+		return
+	case "/cancel":
+		delete(b.drafts, chatID)
+		b.reply(ctx, chatID, "Товар отменён.")
+		return
+	}
+
+	fileID := photoFileID(msg)
+
+	d, ok := b.drafts[chatID]
+
+	if !ok {
+		if fileID == "" {
+			b.reply(ctx, chatID, help)
+			return
+		}
+
+		d = &draft{
+			product: &models.Product{PicturesURL: []string{}},
+			step:    stepPlatforms,
+		}
+		b.drafts[chatID] = d
+	}
+
+	if d.step != stepPlatforms {
+		b.reply(ctx, chatID, "Сначала выберите категорию или отправьте /cancel.")
+		return
+	}
+
+	if fileID != "" {
+		d.photos = append(d.photos, fileID)
+	}
+
+	text := msg.Caption
+	if text == "" {
+		text = msg.Text
+	}
+
+	if err := parseCaption(d.product, text); err != nil {
+		b.reply(ctx, chatID, err.Error())
+	}
+
+	if msg.Document != nil && fileID == "" {
+		b.reply(ctx, chatID, "Нужна фотография в формате JPEG.")
+	}
+
+	b.show(ctx, chatID, d)
+}
+
+// photoFileID returns the largest size of the photo or a JPEG sent as a file.
+func photoFileID(msg *Message) string {
+	if len(msg.Photo) > 0 {
+		return msg.Photo[len(msg.Photo)-1].FileID
+	}
+
+	if msg.Document != nil && msg.Document.MimeType == "image/jpeg" {
+		return msg.Document.FileID
+	}
+
+	return ""
+}
+
+func (b *Bot) handleCallback(ctx context.Context, cb *CallbackQuery) {
+	if !b.allowed(&cb.From) || cb.Message == nil {
+		b.answer(ctx, cb.ID, "Бот только для сотрудников магазина.")
+		return
+	}
+
+	chatID := cb.Message.Chat.ID
+
+	d, ok := b.drafts[chatID]
+	if !ok || d.messageID != cb.Message.MessageID {
+		b.answer(ctx, cb.ID, "Этот товар уже добавлен или отменён.")
+		return
+	}
+
+	switch data := cb.Data; {
+	case data == dataCancel:
+		delete(b.drafts, chatID)
+		b.answer(ctx, cb.ID, "")
+		b.edit(ctx, chatID, d.messageID, "Товар отменён.", nil)
+		return
+	case strings.HasPrefix(data, dataPlatform) && d.step == stepPlatforms:
+		platform := strings.TrimPrefix(data, dataPlatform)
+		if i := slices.Index(d.platforms, platform); i >= 0 {
+			d.platforms = slices.Delete(d.platforms, i, i+1)
+		} else if _, ok := platformNames[platform]; ok {
+			d.platforms = append(d.platforms, platform)
+		}
+	case data == dataNext && d.step == stepPlatforms:
+		if msg := b.check(d); msg != "" {
+			b.answer(ctx, cb.ID, msg)
+			return
+		}
+		d.step = b.nextStep(d, stepPlatforms)
+	case strings.HasPrefix(data, dataVKCategory) && d.step == stepVKCategory:
+		d.product.VK.CategoryID, _ = strconv.Atoi(strings.TrimPrefix(data, dataVKCategory))
+		d.step = b.nextStep(d, stepVKCategory)
+	case strings.HasPrefix(data, dataUcozCategory) && d.step == stepUcozCategory:
+		d.product.Ucoz.CategoryID, _ = strconv.Atoi(strings.TrimPrefix(data, dataUcozCategory))
+		d.step = b.nextStep(d, stepUcozCategory)
+	default:
+		b.answer(ctx, cb.ID, "")
+		return
+	}
+
+	b.answer(ctx, cb.ID, "")
+
+	if d.step == "" {
+		b.add(ctx, chatID, d)
+		return
+	}
+
+	b.show(ctx, chatID, d)
+}
+
+// check returns what is missing in the draft, empty when it can be added.
+func (b *Bot) check(d *draft) string {
+	if len(d.platforms) == 0 {
+		return "Выберите хотя бы одну площадку."
+	}
+
+	// the pictures are set once the draft is added
+	product := *d.product
+	product.MainPictureURL = "-"
+
+	err := b.validate.Struct(&product)
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return ""
+	}
+
+	fields := make([]string, 0, len(validationErrs))
+	for _, e := range validationErrs {
+		fields = append(fields, fieldNames[e.Field()])
+	}
+
+	return "Не хватает: " + strings.Join(fields, ", ") + ". Допишите их сообщением."
+}
+
+var fieldNames = map[string]string{
+	"Title":       "названия",
+	"Description": "описания",
+	"Size":        "размера",
+	"Status":      "состояния",
+	"Price":       "цены",
+}
+
+// nextStep returns the step after the current one, empty when the draft is done.
+func (b *Bot) nextStep(d *draft, current string) string {
+	steps := []string{stepPlatforms}
+
+	if slices.Contains(d.platforms, models.PlatformVK) && len(b.opts.VKCategories) > 0 {
+		steps = append(steps, stepVKCategory)
+	}
+
+	if slices.Contains(d.platforms, models.PlatformUcoz) && len(b.opts.UcozCategories) > 0 {
+		steps = append(steps, stepUcozCategory)
+	}
+
+	i := slices.Index(steps, current)
+	if i+1 < len(steps) {
+		return steps[i+1]
+	}
+
+	return ""
+}
+
+// show sends the draft with the keyboard of its step, or edits the message
+// that was sent before.
+func (b *Bot) show(ctx context.Context, chatID int64, d *draft) {
+	text, keyboard := b.render(d)
+
+	if d.messageID != 0 {
+		b.edit(ctx, chatID, d.messageID, text, keyboard)
+		return
+	}
+
+	msg, err := b.client.SendMessage(ctx, chatID, text, keyboard)
+	if err != nil {
+		b.log.Error("failed to send telegram message", "chatID", chatID, "err", err.Error())
+		return
+	}
+
+	d.messageID = msg.MessageID
+}
+
+func (b *Bot) render(d *draft) (string, *InlineKeyboardMarkup) {
+	p := d.product
+
+	var text strings.Builder
+
+	fmt.Fprintf(&text, "%s\nЦена: %d\nРазмер: %s\nСостояние: %s\nФото: %d\n", p.Title, p.Price, p.Size, p.Status, len(d.photos))
+
+	if p.Description != "" {
+		fmt.Fprintf(&text, "\n%s\n", p.Description)
+	}
+
+	var rows [][]InlineKeyboardButton
+
+	switch d.step {
+	case stepPlatforms:
+		text.WriteString("\nГде опубликовать?")
+
+		var row []InlineKeyboardButton
+		for _, platform := range allPlatforms {
+			label := platformNames[platform]
+			if slices.Contains(d.platforms, platform) {
+				label = "✅ " + label
+			}
+			row = append(row, InlineKeyboardButton{Text: label, CallbackData: dataPlatform + platform})
+		}
+
+		rows = append(rows, row, []InlineKeyboardButton{{Text: "Далее", CallbackData: dataNext}})
+	case stepVKCategory:
+		text.WriteString("\nКатегория VK:")
+		rows = categoryRows(b.opts.VKCategories, dataVKCategory)
+	case stepUcozCategory:
+		text.WriteString("\nКатегория uCoz:")
+		rows = categoryRows(b.opts.UcozCategories, dataUcozCategory)
+	}
+
+	rows = append(rows, []InlineKeyboardButton{{Text: "Отмена", CallbackData: dataCancel}})
+
+	return text.String(), &InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func categoryRows(categories []Category, prefix string) [][]InlineKeyboardButton {
+	rows := make([][]InlineKeyboardButton, 0, len(categories))
+
+	for _, category := range categories {
+		rows = append(rows, []InlineKeyboardButton{{
+			Text:         category.Name,
+			CallbackData: prefix + strconv.Itoa(category.ID),
+		}})
+	}
+
+	return rows
+}
+
+// add saves the draft as a product, its pictures are served by the API from
+// Telegram by file ID.
+func (b *Bot) add(ctx context.Context, chatID int64, d *draft) {
+	delete(b.drafts, chatID)
+
+	p := d.product
+
+	for i, fileID := range d.photos {
+		picture := models.TelegramPicture{
+			FileID: fileID,
+			URL:    strings.TrimRight(b.opts.FilesURL, "/") + FilesPath + url.PathEscape(fileID) + ".jpg",
+		}
+
+		p.Telegram = append(p.Telegram, picture)
+
+		if i == 0 {
+			p.MainPictureURL = picture.URL
+			continue
+		}
+
+		p.PicturesURL = append(p.PicturesURL, picture.URL)
+	}
+
+	p.VK.ToLoad = slices.Contains(d.platforms, models.PlatformVK)
+	p.Ucoz.ToLoad = slices.Contains(d.platforms, models.PlatformUcoz)
+	p.Avito.ToLoad = slices.Contains(d.platforms, models.PlatformAvito)
+
+	text, _ := b.render(&draft{product: p, photos: d.photos})
+
+	jobs, err := b.exchanger.WriteAdd(ctx, p)
+	if err != nil {
+		b.log.Error("failed to add product from telegram", "chatID", chatID, "err", err.Error())
+		b.edit(ctx, chatID, d.messageID, text+"\nНе удалось добавить товар, попробуйте ещё раз.", nil)
+		return
+	}
+
+	b.log.Info("product added from telegram", "chatID", chatID, "productID", p.Id, "jobs", len(jobs))
+
+	published := make([]string, 0, len(d.platforms))
+	for _, platform := range allPlatforms {
+		if slices.Contains(d.platforms, platform) {
+			published = append(published, platformNames[platform])
+		}
+	}
+
+	b.edit(ctx, chatID, d.messageID, fmt.Sprintf("%s\nТовар #%d добавлен, публикуется: %s.", text, p.Id, strings.Join(published, ", ")), nil)
+}
+
+func (b *Bot) reply(ctx context.Context, chatID int64, text string) {
+	if _, err := b.client.SendMessage(ctx, chatID, text, nil); err != nil {
+		b.log.Error("failed to send telegram message", "chatID", chatID, "err", err.Error())
+	}
+}
+
+func (b *Bot) edit(ctx context.Context, chatID int64, messageID int, text string, keyboard *InlineKeyboardMarkup) {
+	if err := b.client.EditMessage(ctx, chatID, messageID, text, keyboard); err != nil {
+		b.log.Error("failed to edit telegram message", "chatID", chatID, "err", err.Error())
+	}
+}
+
+func (b *Bot) answer(ctx context.Context, callbackID string, text string) {
+	if err := b.client.AnswerCallback(ctx, callbackID, text); err != nil {
+		b.log.Error("failed to answer telegram callback", "err", err.Error())
+	}
+}
+
+const help = `Отправьте фото товара с подписью:
+
+Футболка Nike
+Цена: 1500
+Размер: M
+Состояние: новое
+Описание товара
+
+Несколько фото можно отправить альбомом, первое станет главным. Недостающие поля можно дописать следующим сообщением. /cancel отменяет товар.`
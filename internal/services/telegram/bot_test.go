@@ -0,0 +1,313 @@
+package telegram_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/services/telegram"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	token     = "123:secret"
+	staffID   = 1
+	chatID    = 10
+	filesURL  = "https://shop.example/api/v1/"
+	messageID = 100
+)
+
+// botAPI is a fake Bot API server: it hands out the updates once and records
+// the calls of the bot. drained is closed when the bot asks for updates after
+// the last one, so every update has been handled.
+type botAPI struct {
+	mu      sync.Mutex
+	updates []telegram.Update
+	calls   []call
+	drained chan struct{}
+	once    sync.Once
+}
+
+type call struct {
+	method string
+	params map[string]any
+}
+
+func newBotAPI(t *testing.T, updates ...telegram.Update) (*botAPI, *httptest.Server) {
+	t.Helper()
+
+	for i := range updates {
+		updates[i].UpdateID = int64(i + 1)
+	}
+
+	api := &botAPI{updates: updates, drained: make(chan struct{})}
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	return api, srv
+}
+
+func (a *botAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/file/bot"+token+"/photos/file_1.jpg" {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("jpeg"))
+		return
+	}
+
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot"+token+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var params map[string]any
+	json.NewDecoder(r.Body).Decode(&params)
+
+	var result any = true
+
+	switch method {
+	case "getUpdates":
+		offset := int64(params["offset"].(float64))
+
+		a.mu.Lock()
+		var updates []telegram.Update
+		for _, update := range a.updates {
+			if update.UpdateID >= offset {
+				updates = append(updates, update)
+			}
+		}
+		a.mu.Unlock()
+
+		if len(updates) == 0 {
+			a.once.Do(func() { close(a.drained) })
+
+			// long polling: nothing comes until the bot gives up
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+
+		result = updates
+	case "getFile":
+		if params["file_id"] != "photo" {
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: invalid file_id"}`))
+			return
+		}
+
+		result = telegram.File{FileID: "photo", FilePath: "photos/file_1.jpg"}
+	case "sendMessage":
+		result = telegram.Message{MessageID: messageID, Chat: telegram.Chat{ID: chatID}}
+	}
+
+	a.mu.Lock()
+	if method != "getUpdates" {
+		a.calls = append(a.calls, call{method: method, params: params})
+	}
+	a.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// run starts the bot and waits until it has handled every update.
+func (a *botAPI) run(t *testing.T, bot *telegram.Bot) []call {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stopped := make(chan struct{})
+
+	go func() {
+		bot.Run(ctx)
+		close(stopped)
+	}()
+
+	select {
+	case <-a.drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("updates are not handled")
+	}
+
+	cancel()
+	<-stopped
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.calls
+}
+
+type exchanger struct {
+	products []*models.Product
+}
+
+func (e *exchanger) WriteAdd(_ context.Context, product *models.Product) ([]*models.Job, error) {
+	product.Id = 7
+	e.products = append(e.products, product)
+
+	return []*models.Job{{}}, nil
+}
+
+func newBot(srv *httptest.Server, ex *exchanger) *telegram.Bot {
+	opts := telegram.Options{
+		AllowedUsers: []int64{staffID},
+		PollTimeout:  time.Second,
+		FilesURL:     filesURL,
+		VKCategories: []telegram.Category{{ID: 5, Name: "Одежда"}},
+	}
+
+	client := telegram.NewClient(srv.URL, token, opts.PollTimeout)
+
+	return telegram.New(slog.New(slog.NewTextHandler(io.Discard, nil)), client, ex, opts)
+}
+
+func message(from int64, msg telegram.Message) telegram.Update {
+	msg.From = &telegram.User{ID: from}
+	msg.Chat = telegram.Chat{ID: chatID}
+
+	return telegram.Update{Message: &msg}
+}
+
+func callback(from int64, data string) telegram.Update {
+	return telegram.Update{CallbackQuery: &telegram.CallbackQuery{
+		ID:      "cb-" + data,
+		From:    telegram.User{ID: from},
+		Message: &telegram.Message{MessageID: messageID, Chat: telegram.Chat{ID: chatID}},
+		Data:    data,
+	}}
+}
+
+func TestBotAddsProduct(t *testing.T) {
+	api, srv := newBotAPI(t,
+		message(staffID, telegram.Message{
+			Photo:   []telegram.PhotoSize{{FileID: "small"}, {FileID: "big"}},
+			Caption: "Футболка Nike\nЦена: 1 500 ₽\nРазмер: M\nСостояние: новое\nХлопок",
+		}),
+		message(staffID, telegram.Message{Photo: []telegram.PhotoSize{{FileID: "second"}}}),
+		callback(staffID, "platform:vk"),
+		callback(staffID, "platform:avito"),
+		callback(staffID, "next"),
+		callback(staffID, "vk_category:5"),
+	)
+
+	ex := &exchanger{}
+
+	calls := api.run(t, newBot(srv, ex))
+
+	if len(ex.products) != 1 {
+		t.Fatalf("expected one product added, got %d", len(ex.products))
+	}
+
+	p := ex.products[0]
+
+	if p.Title != "Футболка Nike" || p.Price != 1500 || p.Size != "M" || p.Status != "новое" || p.Description != "Хлопок" {
+		t.Errorf("unexpected product fields: %+v", p)
+	}
+
+	if p.MainPictureURL != filesURL+"telegram/files/big.jpg" {
+		t.Errorf("unexpected main picture: %s", p.MainPictureURL)
+	}
+
+	if len(p.PicturesURL) != 1 || p.PicturesURL[0] != filesURL+"telegram/files/second.jpg" {
+		t.Errorf("unexpected pictures: %v", p.PicturesURL)
+	}
+
+	if len(p.Telegram) != 2 || p.Telegram[0].FileID != "big" || p.Telegram[1].FileID != "second" {
+		t.Errorf("unexpected telegram pictures: %v", p.Telegram)
+	}
+
+	if !p.VK.ToLoad || p.VK.CategoryID != 5 || !p.Avito.ToLoad || p.Ucoz.ToLoad {
+		t.Errorf("unexpected platforms: vk %+v avito %+v ucoz %+v", p.VK, p.Avito, p.Ucoz)
+	}
+
+	if calls[0].method != "sendMessage" {
+		t.Fatalf("expected the draft to be sent first, got %s", calls[0].method)
+	}
+
+	last := calls[len(calls)-1]
+	if last.method != "editMessageText" || !strings.Contains(last.params["text"].(string), "Товар #7 добавлен") {
+		t.Fatalf("expected the draft to be edited to added, got %s %v", last.method, last.params)
+	}
+}
+
+func TestBotRefusesStrangers(t *testing.T) {
+	api, srv := newBotAPI(t,
+		message(2, telegram.Message{
+			Photo:   []telegram.PhotoSize{{FileID: "photo"}},
+			Caption: "Футболка\nЦена: 100\nРазмер: M\nСостояние: новое\nОписание",
+		}),
+		callback(2, "next"),
+	)
+
+	ex := &exchanger{}
+
+	calls := api.run(t, newBot(srv, ex))
+
+	if len(ex.products) != 0 {
+		t.Fatal("product added by a stranger")
+	}
+
+	if len(calls) != 2 || calls[0].method != "sendMessage" || calls[1].method != "answerCallbackQuery" {
+		t.Fatalf("expected only refusals, got %v", calls)
+	}
+}
+
+func TestBotAsksForMissingFields(t *testing.T) {
+	api, srv := newBotAPI(t,
+		message(staffID, telegram.Message{
+			Photo:   []telegram.PhotoSize{{FileID: "photo"}},
+			Caption: "Футболка",
+		}),
+		callback(staffID, "platform:avito"),
+		callback(staffID, "next"),
+	)
+
+	ex := &exchanger{}
+
+	calls := api.run(t, newBot(srv, ex))
+
+	if len(ex.products) != 0 {
+		t.Fatal("incomplete product added")
+	}
+
+	last := calls[len(calls)-1]
+	if last.method != "answerCallbackQuery" || !strings.HasPrefix(last.params["text"].(string), "Не хватает") {
+		t.Fatalf("expected missing fields answer, got %s %v", last.method, last.params)
+	}
+}
+
+func TestClientOpenFile(t *testing.T) {
+	_, srv := newBotAPI(t)
+
+	client := telegram.NewClient(srv.URL, token, time.Second)
+
+	body, contentType, err := client.OpenFile(context.Background(), "photo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, _ := io.ReadAll(body)
+	if string(data) != "jpeg" || contentType != "image/jpeg" {
+		t.Fatalf("unexpected file %q of %s", data, contentType)
+	}
+
+	if _, _, err := client.OpenFile(context.Background(), "unknown"); !errors.Is(err, telegram.ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+
+	srv.Close()
+
+	_, err = client.GetFile(context.Background(), "photo")
+	if err == nil || strings.Contains(err.Error(), token) {
+		t.Fatalf("expected an error without the token, got %v", err)
+	}
+}
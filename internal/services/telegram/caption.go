@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// captionFields map the keys of "key: value" caption lines to product fields,
+// in English and in Russian.
+var captionFields = map[string]func(p *models.Product, value string) error{
+	"price":       setPrice,
+	"цена":        setPrice,
+	"size":        func(p *models.Product, v string) error { p.Size = v; return nil },
+	"размер":      func(p *models.Product, v string) error { p.Size = v; return nil },
+	"status":      func(p *models.Product, v string) error { p.Status = v; return nil },
+	"состояние":   func(p *models.Product, v string) error { p.Status = v; return nil },
+	"description": func(p *models.Product, v string) error { p.Description = v; return nil },
+	"описание":    func(p *models.Product, v string) error { p.Description = v; return nil },
+}
+
+// parseCaption fills the product from the caption of a photo:
+//
+//	Футболка Nike
+//	Цена: 1500
+//	Размер: M
+//	Состояние: новое
+//	Хлопок, носилась один раз.
+//
+// The first other line is the title and the rest is the description. Only
+// the fields present in the caption are set, so the product can be completed
+// by the next message.
+func parseCaption(p *models.Product, caption string) error {
+	var description []string
+
+	for _, line := range strings.Split(caption, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			if set, ok := captionFields[strings.ToLower(strings.TrimSpace(key))]; ok {
+				if err := set(p, strings.TrimSpace(value)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if p.Title == "" {
+			p.Title = line
+			continue
+		}
+
+		description = append(description, line)
+	}
+
+	if len(description) > 0 {
+		p.Description = strings.Join(description, "\n")
+	}
+
+	return nil
+}
+
+// setPrice reads a price like "1500", "1 500 ₽" or "1500 руб".
+func setPrice(p *models.Product, value string) error {
+	compact := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, value)
+
+	digits := strings.IndexFunc(compact, func(r rune) bool { return !unicode.IsDigit(r) })
+	if digits == -1 {
+		digits = len(compact)
+	}
+
+	price, err := strconv.Atoi(compact[:digits])
+	if err != nil || price < 1 {
+		return fmt.Errorf("цена %q не число", value)
+	}
+
+	p.Price = price
+
+	return nil
+}
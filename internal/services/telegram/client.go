@@ -0,0 +1,263 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrFileNotFound is returned for a file ID the Bot API doesn't know.
+var ErrFileNotFound = errors.New("telegram file not found")
+
+// Client is a client of the Telegram Bot API. The API URL is configurable, so
+// the bot can be run against a local Bot API server or a fake one.
+type Client struct {
+	httpClient *http.Client
+	apiURL     string
+	token      string
+}
+
+// NewClient creates a client, pollTimeout is the long polling timeout of
+// getUpdates, requests wait a bit longer than that.
+func NewClient(apiURL string, token string, pollTimeout time.Duration) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: pollTimeout + 10*time.Second},
+		apiURL:     strings.TrimRight(apiURL, "/"),
+		token:      token,
+	}
+}
+
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+}
+
+type Chat struct {
+	ID int64 `json:"id"`
+}
+
+type PhotoSize struct {
+	FileID   string `json:"file_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int    `json:"file_size"`
+}
+
+type Document struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+}
+
+type Message struct {
+	MessageID    int         `json:"message_id"`
+	From         *User       `json:"from"`
+	Chat         Chat        `json:"chat"`
+	Text         string      `json:"text"`
+	Caption      string      `json:"caption"`
+	Photo        []PhotoSize `json:"photo"`
+	Document     *Document   `json:"document"`
+	MediaGroupID string      `json:"media_group_id"`
+}
+
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    User     `json:"from"`
+	Message *Message `json:"message"`
+	Data    string   `json:"data"`
+}
+
+type Update struct {
+	UpdateID      int64          `json:"update_id"`
+	Message       *Message       `json:"message"`
+	CallbackQuery *CallbackQuery `json:"callback_query"`
+}
+
+type File struct {
+	FileID   string `json:"file_id"`
+	FilePath string `json:"file_path"`
+}
+
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// apiResponse is the envelope of Bot API answers.
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+type apiError struct {
+	method      string
+	code        int
+	description string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s failed: %d %s", e.method, e.code, e.description)
+}
+
+// GetUpdates waits up to timeout for updates after offset.
+func (c *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	var updates []Update
+
+	err := c.call(ctx, "getUpdates", map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message", "callback_query"},
+	}, &updates)
+
+	return updates, err
+}
+
+func (c *Client) SendMessage(ctx context.Context, chatID int64, text string, keyboard *InlineKeyboardMarkup) (*Message, error) {
+	params := map[string]any{
+		"chat_id": chatID,
+		"text":    text,
+	}
+
+	if keyboard != nil {
+		params["reply_markup"] = keyboard
+	}
+
+	var message Message
+
+	if err := c.call(ctx, "sendMessage", params, &message); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+// EditMessage replaces the text and the keyboard of a message sent by the bot,
+// a nil keyboard removes it.
+func (c *Client) EditMessage(ctx context.Context, chatID int64, messageID int, text string, keyboard *InlineKeyboardMarkup) error {
+	params := map[string]any{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"text":       text,
+	}
+
+	if keyboard != nil {
+		params["reply_markup"] = keyboard
+	}
+
+	return c.call(ctx, "editMessageText", params, nil)
+}
+
+// AnswerCallback stops the loading indicator of the button, a non-empty text
+// is shown to the user.
+func (c *Client) AnswerCallback(ctx context.Context, callbackID string, text string) error {
+	return c.call(ctx, "answerCallbackQuery", map[string]any{
+		"callback_query_id": callbackID,
+		"text":              text,
+	}, nil)
+}
+
+func (c *Client) GetFile(ctx context.Context, fileID string) (*File, error) {
+	var file File
+
+	if err := c.call(ctx, "getFile", map[string]any{"file_id": fileID}, &file); err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.code == http.StatusBadRequest {
+			return nil, fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// OpenFile downloads the file, the caller must close the body. The download
+// link has the token in it and expires, so it's never handed out.
+func (c *Client) OpenFile(ctx context.Context, fileID string) (io.ReadCloser, string, error) {
+	file, err := c.GetFile(ctx, fileID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/file/bot%s/%s", c.apiURL, c.token, file.FilePath), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return nil, "", fmt.Errorf("failed to download file: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("failed to download file: unexpected status: %s", resp.Status)
+	}
+
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params map[string]any, dst any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s params: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/bot%s/%s", c.apiURL, c.token, method), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// the URL has the token in it, so only the cause is returned
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var answer apiResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return fmt.Errorf("failed to decode %s answer: %s", method, resp.Status)
+	}
+
+	if !answer.OK {
+		return &apiError{method: method, code: answer.ErrorCode, description: answer.Description}
+	}
+
+	if dst == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(answer.Result, dst); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+
+	return nil
+}
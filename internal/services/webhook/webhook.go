@@ -0,0 +1,298 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"prodLoaderREST/internal/domain/models"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	deliveryBatch = 20
+	// maxErrorBody is how much of a failed response is kept in the delivery log.
+	maxErrorBody = 512
+)
+
+// Headers of a webhook request. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" with the secret, prefixed with "sha256=".
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var events = []string{models.WebhookEventPublished, models.WebhookEventFailed, models.WebhookEventUnpublished}
+
+type Storage interface {
+	SaveWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error
+	DueWebhookDeliveries(ctx context.Context, limit int) ([]*models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery, delay time.Duration) error
+	RedeliverWebhook(ctx context.Context, deliveryID int64) error
+}
+
+// Options are the endpoints the events are sent to and how failed deliveries
+// are retried. Without URLs events are not sent.
+type Options struct {
+	URLs         []string
+	Secret       string
+	Events       []string
+	Timeout      time.Duration
+	PollInterval time.Duration
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+}
+
+func (o Options) Validate() error {
+	if len(o.URLs) == 0 {
+		return nil
+	}
+
+	if o.Secret == "" {
+		return fmt.Errorf("webhook secret is empty")
+	}
+
+	for _, u := range o.URLs {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid webhook URL: %s", u)
+		}
+	}
+
+	for _, event := range o.Events {
+		if !slices.Contains(events, event) {
+			return fmt.Errorf("unknown webhook event: %s", event)
+		}
+	}
+
+	if o.Timeout <= 0 {
+		return fmt.Errorf("webhook timeout must be positive")
+	}
+
+	if o.PollInterval <= 0 {
+		return fmt.Errorf("webhook poll interval must be positive")
+	}
+
+	if o.MaxAttempts <= 0 {
+		return fmt.Errorf("webhook max attempts must be positive")
+	}
+
+	if o.BackoffBase <= 0 || o.BackoffMax < o.BackoffBase {
+		return fmt.Errorf("webhook backoff must be positive and its max not less than its base")
+	}
+
+	return nil
+}
+
+type Notifier struct {
+	log     *slog.Logger
+	storage Storage
+	opts    Options
+	client  *http.Client
+	wakeup  chan struct{}
+}
+
+func New(log *slog.Logger, storage Storage, opts Options) *Notifier {
+	return &Notifier{
+		log:     log,
+		storage: storage,
+		opts:    opts,
+		client:  &http.Client{Timeout: opts.Timeout},
+		wakeup:  make(chan struct{}, 1),
+	}
+}
+
+// Notify queues the event for every URL, it does nothing when the event is
+// not enabled. Deliveries are sent by Run.
+func (n *Notifier) Notify(ctx context.Context, event *models.WebhookEvent) {
+	if len(n.opts.URLs) == 0 || !slices.Contains(n.opts.Events, event.Event) {
+		return
+	}
+
+	log := n.log.With("event", event.Event, "jobID", event.JobID)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Error("failed to marshal webhook event", "err", err.Error())
+		return
+	}
+
+	deliveries := make([]*models.WebhookDelivery, 0, len(n.opts.URLs))
+
+	for _, u := range n.opts.URLs {
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			Event:   event.Event,
+			URL:     u,
+			Payload: payload,
+		})
+	}
+
+	if err := n.storage.SaveWebhookDeliveries(ctx, deliveries); err != nil {
+		log.Error("failed to save webhook deliveries", "err", err.Error())
+		return
+	}
+
+	n.notify()
+}
+
+// Redeliver returns a failed delivery to the queue.
+func (n *Notifier) Redeliver(ctx context.Context, deliveryID int64) error {
+	if err := n.storage.RedeliverWebhook(ctx, deliveryID); err != nil {
+		return err
+	}
+
+	n.notify()
+
+	return nil
+}
+
+func (n *Notifier) notify() {
+	select {
+	case n.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends the due deliveries until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	if len(n.opts.URLs) == 0 {
+		n.log.Info("webhooks are disabled")
+		return
+	}
+
+	ticker := time.NewTicker(n.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n.send(ctx)
+
+		select {
+		case <-ctx.Done():
+			n.log.Info("webhook notifier stopped")
+			return
+		case <-ticker.C:
+		case <-n.wakeup:
+		}
+	}
+}
+
+func (n *Notifier) send(ctx context.Context) {
+	for {
+		deliveries, err := n.storage.DueWebhookDeliveries(ctx, deliveryBatch)
+		if err != nil {
+			n.log.Error("failed to get webhook deliveries", "err", err.Error())
+			return
+		}
+
+		for _, d := range deliveries {
+			if ctx.Err() != nil {
+				return
+			}
+
+			n.deliver(ctx, d)
+		}
+
+		if len(deliveries) < deliveryBatch {
+			return
+		}
+	}
+}
+
+// deliver sends the delivery once and saves the result: delivered, retry
+// after a backoff, or failed once attempts are exhausted.
+func (n *Notifier) deliver(ctx context.Context, d *models.WebhookDelivery) {
+	log := n.log.With("deliveryID", d.ID, "event", d.Event, "url", d.URL)
+
+	d.Attempts++
+
+	code, err := n.post(ctx, d)
+
+	d.ResponseCode = code
+
+	var delay time.Duration
+
+	switch {
+	case err == nil:
+		d.Status = models.WebhookStatusDelivered
+		d.LastError = ""
+		log.Debug("webhook delivered", "attempt", d.Attempts)
+	case d.Attempts >= n.opts.MaxAttempts:
+		d.Status = models.WebhookStatusFailed
+		d.LastError = err.Error()
+		log.Error("webhook delivery failed", "attempt", d.Attempts, "err", err.Error())
+	default:
+		d.LastError = err.Error()
+		delay = n.backoff(d.Attempts)
+		log.Warn("webhook delivery failed, will retry", "attempt", d.Attempts, "delay", delay, "err", err.Error())
+	}
+
+	// the result is saved even if the notifier is already stopping
+	if err := n.storage.UpdateWebhookDelivery(context.WithoutCancel(ctx), d, delay); err != nil {
+		log.Error("failed to save webhook delivery", "err", err.Error())
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, d *models.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(n.opts.Secret, timestamp, d.Payload))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp.StatusCode, fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature of the body sent at timestamp, receivers compare
+// it with the X-Webhook-Signature header.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff doubles the delay with every attempt, up to BackoffMax.
+func (n *Notifier) backoff(attempt int) time.Duration {
+	delay := n.opts.BackoffBase
+
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= n.opts.BackoffMax {
+			return n.opts.BackoffMax
+		}
+	}
+
+	return delay
+}
@@ -0,0 +1,100 @@
+package pictureManager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps pictures on the local disk as <folder>/<first 2 chars of the key>/<key>.
+type FileStore struct {
+	folder string
+}
+
+func NewFileStore(folder string) *FileStore {
+	return &FileStore{folder: folder}
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.folder, key[:2], key)
+}
+
+// Put writes to a temporary file first, so a reader never sees a half-written picture.
+func (s *FileStore) Put(_ context.Context, key string, data []byte) error {
+	filePath := s.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return fmt.Errorf("ошибка при создании папки '%s': %w", filepath.Dir(filePath), err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании файла '%s': %w", filePath, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при записи данных в файл '%s': %w", tmp.Name(), err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка при записи данных в файл '%s': %w", tmp.Name(), err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to chmod file: %s, error: %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to move file to '%s': %w", filePath, err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) Open(_ context.Context, key string) (*Object, error) {
+	filePath := s.path(key)
+
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, filePath)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %s, error: %w", filePath, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat file: %s, error: %w", filePath, err)
+	}
+
+	return &Object{ReadSeekCloser: file, ModTime: info.ModTime()}, nil
+}
+
+func (s *FileStore) Exists(_ context.Context, key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to stat file: %s, error: %w", s.path(key), err)
+	}
+
+	return true, nil
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	filePath := s.path(key)
+
+	err := os.Remove(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove file: %s, error: %w", filePath, err)
+	}
+
+	return nil
+}
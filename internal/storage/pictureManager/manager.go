@@ -1,82 +1,283 @@
 package pictureManager
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
-	"path/filepath"
-	"strconv"
+	"prodLoaderREST/internal/storage"
+	"slices"
 	"strings"
 )
 
-var (
-	destinationFolder = "../../storage/jpg"
-	ErrNotFound       = fmt.Errorf("file not found")
-)
+// maxPictureSize is the VK limit for a market photo.
+const maxPictureSize = 50 << 20
+
+var ErrNotFound = fmt.Errorf("file not found")
+
+// Storage keeps the keys of the pictures of a product by position, 0 is the main picture.
+type Storage interface {
+	SetPictureKeys(ctx context.Context, productID int64, keys []string) error
+	PictureKey(ctx context.Context, productID int64, position int) (string, error)
+	ClearPictureKeys(ctx context.Context, productID int64) ([]string, error)
+	PictureKeyUsed(ctx context.Context, key string) (bool, error)
+}
+
+// Fetcher downloads a picture from a URL given by a user.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) ([]byte, error)
+}
 
-func SavePicture(productID int, url string) error {
+// Manager downloads and normalizes the pictures of products and keeps them
+// in a PictureStore, the keys are saved with the product. Uploaded pictures
+// are served at <publicURL>/pictures/<id>, products reference them by that URL.
+type Manager struct {
+	store     PictureStore
+	storage   Storage
+	fetcher   Fetcher
+	publicURL string
+}
 
-	if !strings.HasSuffix(strings.ToLower(url), ".jpg") && !strings.HasSuffix(strings.ToLower(url), ".jpeg") {
-		// Если нет расширения .jpg/.jpeg, пробуем определить из Content-Type или добавляем .jpg
-		return fmt.Errorf("URL does not point to a valid .jpg or .jpeg image: %s", url)
+func New(store PictureStore, storage Storage, fetcher Fetcher, publicURL string) *Manager {
+	return &Manager{
+		store:     store,
+		storage:   storage,
+		fetcher:   fetcher,
+		publicURL: strings.TrimRight(publicURL, "/"),
 	}
+}
 
-	id := strconv.Itoa(productID)
+// Upload normalizes and stores the picture and returns its ID.
+func (m *Manager) Upload(ctx context.Context, data []byte) (string, error) {
+	if len(data) > maxPictureSize {
+		return "", fmt.Errorf("%w: larger than %d bytes", ErrInvalidPicture, maxPictureSize)
+	}
 
-	resp, err := http.Get(url)
+	key, err := m.put(ctx, data)
 	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(key, ".jpg"), nil
+}
+
+// PictureURL returns the URL of an uploaded picture, ErrNotFound for an
+// unknown ID.
+func (m *Manager) PictureURL(ctx context.Context, id string) (string, error) {
+	if !validID(id) {
+		return "", fmt.Errorf("%w: picture %s", ErrNotFound, id)
+	}
+
+	exists, err := m.store.Exists(ctx, keyOf(id))
+	if err != nil {
+		return "", err
+	}
+
+	if !exists {
+		return "", fmt.Errorf("%w: picture %s", ErrNotFound, id)
+	}
+
+	return m.publicURL + "/pictures/" + id, nil
+}
+
+// OpenByID opens an uploaded picture, a thumbnail of the width when it's not 0.
+func (m *Manager) OpenByID(ctx context.Context, id string, width int) (*Object, error) {
+	if !validID(id) {
+		return nil, fmt.Errorf("%w: picture %s", ErrNotFound, id)
+	}
+
+	return m.open(ctx, keyOf(id), width)
+}
+
+// uploadedID returns the ID of an uploaded picture from its URL.
+func (m *Manager) uploadedID(picURL string) (string, bool) {
+	id, ok := strings.CutPrefix(picURL, m.publicURL+"/pictures/")
+	if !ok || !validID(id) {
+		return "", false
+	}
+
+	return id, true
+}
+
+// SavePictures downloads the pictures of the product, the main one first,
+// normalizes them and stores them. Identical pictures are stored once and
+// uploaded ones are not downloaded again. A picture that fails is skipped and
+// reported in the error, the rest are stored anyway.
+func (m *Manager) SavePictures(ctx context.Context, productID int64, urls []string) error {
+	keys := make([]string, len(urls))
+
+	var errs []error
+
+	for n, picURL := range urls {
+		key, err := m.savePicture(ctx, picURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("picture %d %s: %w", n, picURL, err))
+			continue
+		}
+
+		keys[n] = key
+	}
+
+	if err := m.storage.SetPictureKeys(ctx, productID, keys); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexepted status code: %s", resp.Status)
+	return errors.Join(errs...)
+}
+
+func (m *Manager) savePicture(ctx context.Context, picURL string) (string, error) {
+	if id, ok := m.uploadedID(picURL); ok {
+		exists, err := m.store.Exists(ctx, keyOf(id))
+		if err != nil {
+			return "", err
+		}
+
+		if exists {
+			return keyOf(id), nil
+		}
 	}
 
-	fileName := fmt.Sprintf("%s.jpg", id)
+	data, err := m.fetcher.Fetch(ctx, picURL)
+	if err != nil {
+		return "", err
+	}
 
-	err = os.MkdirAll(destinationFolder, os.ModePerm) // 0755 permissions
+	return m.put(ctx, data)
+}
+
+// put normalizes the picture and stores it unless it's already stored.
+func (m *Manager) put(ctx context.Context, data []byte) (string, error) {
+	data, err := Normalize(data)
 	if err != nil {
-		return fmt.Errorf("ошибка при создании папки '%s': %w", destinationFolder, err)
+		return "", err
 	}
 
-	// Полный путь к файлу
-	filePath := filepath.Join(destinationFolder, fileName)
+	key := Key(data)
 
-	// 5. Создаем локальный файл
-	out, err := os.Create(filePath)
+	exists, err := m.store.Exists(ctx, key)
 	if err != nil {
-		return fmt.Errorf("ошибка при создании файла '%s': %w", filePath, err)
+		return "", err
+	}
+
+	if !exists {
+		if err := m.store.Put(ctx, key, data); err != nil {
+			return "", err
+		}
 	}
-	defer out.Close() // Закрываем файл при завершении функции
 
-	// 6. Копируем данные из тела ответа в локальный файл
-	_, err = io.Copy(out, resp.Body)
+	return key, nil
+}
+
+// Picture returns the n-th stored picture of the product, 0 is the main one.
+func (m *Manager) Picture(ctx context.Context, productID int64, n int) ([]byte, error) {
+	obj, err := m.Open(ctx, productID, n, 0)
 	if err != nil {
-		return fmt.Errorf("ошибка при записи данных в файл '%s': %w", filePath, err)
+		return nil, err
 	}
+	defer obj.Close()
 
-	fmt.Printf("Изображение успешно загружено и сохранено как '%s'\n", filePath)
-	return nil
+	return io.ReadAll(obj)
+}
 
+// Open opens the n-th stored picture of the product, a thumbnail of the width
+// when it's not 0.
+func (m *Manager) Open(ctx context.Context, productID int64, n int, width int) (*Object, error) {
+	key, err := m.storage.PictureKey(ctx, productID, n)
+	if err != nil {
+		if errors.Is(err, storage.ErrPictureNotFound) {
+			return nil, fmt.Errorf("%w: product %d picture %d", ErrNotFound, productID, n)
+		}
+		return nil, err
+	}
+
+	return m.open(ctx, key, width)
 }
 
-func Picture(productID string) ([]byte, error) {
+// open opens the picture or its thumbnail, the thumbnail is made on first use
+// and stored next to the picture.
+func (m *Manager) open(ctx context.Context, key string, width int) (*Object, error) {
+	if width != 0 {
+		if !slices.Contains(ThumbnailWidths, width) {
+			return nil, fmt.Errorf("%w: %d, allowed %v", ErrInvalidWidth, width, ThumbnailWidths)
+		}
+
+		thumbKey := variantKey(key, width)
+
+		obj, err := m.store.Open(ctx, thumbKey)
+		if errors.Is(err, ErrNotFound) {
+			if err := m.makeThumbnail(ctx, key, thumbKey, width); err != nil {
+				return nil, err
+			}
+
+			obj, err = m.store.Open(ctx, thumbKey)
+		}
 
-	fileName := fmt.Sprintf("%s.jpg", productID)
+		if err != nil {
+			return nil, err
+		}
 
-	filePath := filepath.Join(destinationFolder, fileName)
+		obj.ETag = etag(thumbKey)
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("%w: %s", ErrNotFound, filePath)
+		return obj, nil
 	}
 
-	file, err := os.ReadFile(filePath)
+	obj, err := m.store.Open(ctx, key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %s, error: %w", filePath, err)
+		return nil, err
+	}
+
+	obj.ETag = etag(key)
+
+	return obj, nil
+}
+
+func (m *Manager) makeThumbnail(ctx context.Context, key string, thumbKey string, width int) error {
+	obj, err := m.store.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	data, err := thumbnail(obj, width)
+	if err != nil {
+		return fmt.Errorf("failed to make thumbnail of %s: %w", key, err)
+	}
+
+	return m.store.Put(ctx, thumbKey, data)
+}
+
+// DeletePictures forgets the pictures of the product and removes the ones no
+// other product uses with their thumbnails. Pictures replaced by an update are
+// left in the store.
+func (m *Manager) DeletePictures(ctx context.Context, productID int64) error {
+	keys, err := m.storage.ClearPictureKeys(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, key := range keys {
+		used, err := m.storage.PictureKeyUsed(ctx, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if used {
+			continue
+		}
+
+		for _, width := range ThumbnailWidths {
+			if err := m.store.Delete(ctx, variantKey(key, width)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if err := m.store.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	fmt.Printf("Picture found: %s\n", filePath)
-	return file, nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,134 @@
+package pictureManager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// VK market photo limits: both sides at least 400px and the sum of the sides
+// at most 14000px.
+const (
+	minSide = 400
+	maxSum  = 14000
+
+	// maxPixels is checked before decoding, a small file of a huge picture
+	// would take gigabytes once decoded.
+	maxPixels = 40_000_000
+
+	jpegQuality = 90
+)
+
+var (
+	ErrInvalidPicture    = errors.New("invalid picture")
+	ErrUnsupportedFormat = fmt.Errorf("%w: unsupported format", ErrInvalidPicture)
+)
+
+type decoder struct {
+	decode       func(r io.Reader) (image.Image, error)
+	decodeConfig func(r io.Reader) (image.Config, error)
+}
+
+// decoders are the accepted formats by sniffed content type.
+var decoders = map[string]decoder{
+	"image/jpeg": {jpeg.Decode, jpeg.DecodeConfig},
+	"image/png":  {png.Decode, png.DecodeConfig},
+	"image/webp": {webp.Decode, webp.DecodeConfig},
+}
+
+// Normalize turns a JPEG, PNG or WebP picture into a JPEG that fits the VK
+// limits. The format is sniffed from the content, not taken from the URL or
+// headers. Re-encoding drops EXIF and other metadata, the orientation from
+// EXIF is applied to the pixels first.
+func Normalize(data []byte) ([]byte, error) {
+	contentType := http.DetectContentType(data)
+
+	dec, ok := decoders[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+	}
+
+	config, err := dec.decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode %s: %s", ErrInvalidPicture, contentType, err)
+	}
+
+	if config.Width < 1 || config.Height < 1 || config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d is more than %d pixels", ErrInvalidPicture, config.Width, config.Height, maxPixels)
+	}
+
+	// a long narrow picture can't fit both limits
+	if w, h := fit(config.Width, config.Height); w+h > maxSum || min(w, h) < minSide {
+		return nil, fmt.Errorf("%w: %dx%d can't fit %dpx sides and %dpx sum", ErrInvalidPicture, config.Width, config.Height, minSide, maxSum)
+	}
+
+	img, err := dec.decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode %s: %s", ErrInvalidPicture, contentType, err)
+	}
+
+	if contentType == "image/jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	img = resize(img)
+
+	var buf bytes.Buffer
+
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resize scales the picture up to the minimum or down to the maximum keeping
+// the aspect ratio. It always returns an opaque copy, transparent pixels of
+// PNG and WebP are put on white instead of turning black in JPEG.
+func resize(img image.Image) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	dw, dh := fit(w, h)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	if dw == w && dh == h {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Over)
+		return dst
+	}
+
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	return dst
+}
+
+// fit returns the size the picture is scaled to. A picture narrower than
+// 1:34 can't have both the minimum side and the maximum sum, Normalize refuses it.
+func fit(w, h int) (int, int) {
+	if short := min(w, h); short < minSide {
+		scale := float64(minSide) / float64(short)
+
+		// rounding must not go below the minimum
+		return max(int(float64(w)*scale+0.5), minSide), max(int(float64(h)*scale+0.5), minSide)
+	}
+
+	if w+h > maxSum {
+		scale := float64(maxSum) / float64(w+h)
+
+		// truncated, so the sum doesn't go above the maximum
+		return int(float64(w) * scale), int(float64(h) * scale)
+	}
+
+	return w, h
+}
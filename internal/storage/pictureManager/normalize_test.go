@@ -0,0 +1,80 @@
+package pictureManager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestNormalizeSize(t *testing.T) {
+	tests := []struct {
+		w, h         int
+		wantW, wantH int
+	}{
+		{100, 50, 800, 400},
+		{500, 600, 500, 600},
+		{14000, 1000, 13066, 933},
+		{10000, 300, 13333, 400},
+	}
+
+	for _, tt := range tests {
+		data, err := Normalize(encodePNG(t, tt.w, tt.h))
+		if err != nil {
+			t.Fatalf("%dx%d: %v", tt.w, tt.h, err)
+		}
+
+		config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if config.Width != tt.wantW || config.Height != tt.wantH {
+			t.Errorf("%dx%d: got %dx%d, want %dx%d", tt.w, tt.h, config.Width, config.Height, tt.wantW, tt.wantH)
+		}
+
+		if config.Width+config.Height > maxSum || min(config.Width, config.Height) < minSide {
+			t.Errorf("%dx%d: %dx%d is out of the VK limits", tt.w, tt.h, config.Width, config.Height)
+		}
+	}
+}
+
+func TestNormalizeRejectsNarrow(t *testing.T) {
+	for _, size := range [][2]int{{20000, 300}, {300, 20000}, {20000, 400}} {
+		_, err := Normalize(encodePNG(t, size[0], size[1]))
+		if !errors.Is(err, ErrInvalidPicture) {
+			t.Errorf("%dx%d: expected ErrInvalidPicture, got %v", size[0], size[1], err)
+		}
+	}
+}
+
+// TestNormalizeRejectsBomb claims a huge size in the header of a tiny PNG,
+// it must be refused before decoding.
+func TestNormalizeRejectsBomb(t *testing.T) {
+	data := encodePNG(t, 1, 1)
+
+	// IHDR: length, type, width, height... and the CRC of type and data
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	_, err := Normalize(data)
+	if !errors.Is(err, ErrInvalidPicture) {
+		t.Fatalf("expected ErrInvalidPicture, got %v", err)
+	}
+}
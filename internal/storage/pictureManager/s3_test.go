@@ -0,0 +1,236 @@
+package pictureManager
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "minio"
+	testSecretKey = "minio-secret"
+	testRegion    = "us-east-1"
+	testBucket    = "pictures"
+)
+
+// bucket is a MinIO-like stand-in: objects are kept in memory and every
+// request must carry a valid Signature Version 4, checked the way the server does.
+type bucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	secret  string
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	if code, err := b.verify(r, body); err != "" {
+		w.WriteHeader(code)
+		w.Write([]byte("<Error><Code>" + err + "</Code></Error>"))
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.EscapedPath(), "/"+testBucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<Error><Code>NoSuchBucket</Code></Error>"))
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, exists := b.objects[key]
+
+	switch r.Method {
+	case http.MethodPut:
+		b.objects[key] = body
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Last-Modified", "Wed, 01 May 2024 10:00:00 GMT")
+		w.Write(data)
+	case http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verify returns the status and the S3 error code of a badly signed request.
+func (b *bucket) verify(r *http.Request, body []byte) (int, string) {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return http.StatusForbidden, "AccessDenied"
+	}
+
+	fields := make(map[string]string)
+	for _, field := range strings.Split(auth, ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		fields[name] = value
+	}
+
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[0] != testAccessKey || credential[2] != testRegion ||
+		credential[3] != "s3" || credential[4] != "aws4_request" {
+		return http.StatusForbidden, "InvalidAccessKeyId"
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if sum := sha256.Sum256(body); payloadHash != hex.EncodeToString(sum[:]) {
+		return http.StatusBadRequest, "XAmzContentSHA256Mismatch"
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if signed, err := time.Parse("20060102T150405Z", amzDate); err != nil || time.Since(signed).Abs() > 15*time.Minute {
+		return http.StatusForbidden, "RequestTimeTooSkewed"
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := r.Method + "\n" + r.URL.EscapedPath() + "\n" + r.URL.RawQuery + "\n" +
+		canonicalHeaders.String() + "\n" + fields["SignedHeaders"] + "\n" + payloadHash
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := strings.Join(credential[1:], "/")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + b.secret)
+	for _, part := range credential[1:] {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(fields["Signature"])) {
+		return http.StatusForbidden, "SignatureDoesNotMatch"
+	}
+
+	return 0, ""
+}
+
+func newTestS3(t *testing.T, secret string) (*S3Store, *bucket) {
+	t.Helper()
+
+	b := &bucket{objects: make(map[string][]byte), secret: testSecretKey}
+
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+
+	opts := S3Options{
+		Endpoint:  srv.URL + "/",
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: secret,
+		Timeout:   5 * time.Second,
+	}
+
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewS3Store(opts), b
+}
+
+func TestS3Store(t *testing.T) {
+	store, b := newTestS3(t, testSecretKey)
+	ctx := context.Background()
+
+	key := "1/main picture.jpg"
+
+	if err := store.Put(ctx, key, []byte("jpeg")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := b.objects["1%2Fmain%20picture.jpg"]; !ok {
+		t.Fatalf("object is not stored under the escaped key: %v", b.objects)
+	}
+
+	exists, err := store.Exists(ctx, key)
+	if err != nil || !exists {
+		t.Fatalf("expected the object to exist, got %v %v", exists, err)
+	}
+
+	obj, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := io.ReadAll(obj)
+	obj.Close()
+
+	if string(data) != "jpeg" {
+		t.Fatalf("unexpected object %q", data)
+	}
+
+	if want := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC); !obj.ModTime.Equal(want) {
+		t.Fatalf("expected mod time %v, got %v", want, obj.ModTime)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// deleting again is not an error, the key is already gone
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected the object to be deleted, got %v %v", exists, err)
+	}
+
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestS3StoreWrongSecret(t *testing.T) {
+	store, b := newTestS3(t, "wrong")
+
+	err := store.Put(context.Background(), "1/main.jpg", []byte("jpeg"))
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("expected the signature to be refused, got %v", err)
+	}
+
+	if len(b.objects) != 0 {
+		t.Fatal("object stored with a wrong signature")
+	}
+
+	if _, err := store.Exists(context.Background(), "1/main.jpg"); err == nil {
+		t.Fatal("expected an error for a refused HEAD")
+	}
+}
+
+// TestSigningKey checks the key derivation against the example of the AWS
+// Signature Version 4 documentation.
+func TestSigningKey(t *testing.T) {
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
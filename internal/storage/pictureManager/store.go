@@ -0,0 +1,70 @@
+package pictureManager
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"time"
+)
+
+// PictureStore keeps pictures by key. Keys are content-addressed, so Put of a
+// key that exists stores the same bytes again and can be skipped.
+type PictureStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Open returns ErrNotFound for a missing key, the caller must close the object.
+	Open(ctx context.Context, key string) (*Object, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete of a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Key returns the key of a normalized picture: its ID with the extension.
+func Key(data []byte) string {
+	return keyOf(ID(data))
+}
+
+// ID returns the ID of a normalized picture: the hex SHA-256 of its bytes.
+// Uploaded pictures are referenced by ID.
+func ID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func keyOf(id string) string {
+	return id + ".jpg"
+}
+
+// validID reports whether id can be an ID, IDs come from clients and must not
+// turn into arbitrary keys.
+func validID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+
+	for _, r := range id {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Object is a stored picture opened for reading. ETag is set by the manager,
+// the content of a key never changes, so the key is a strong validator.
+type Object struct {
+	io.ReadSeekCloser
+	ModTime time.Time
+	ETag    string
+}
+
+// bytesObject is an object read into memory.
+type bytesObject struct {
+	*bytes.Reader
+}
+
+func (bytesObject) Close() error {
+	return nil
+}
@@ -0,0 +1,59 @@
+package pictureManager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// ThumbnailWidths are the widths thumbnails are made of, a fixed list keeps
+// clients from filling the store with variants.
+var ThumbnailWidths = []int{100, 200, 400, 800}
+
+const thumbnailQuality = 80
+
+var ErrInvalidWidth = errors.New("invalid thumbnail width")
+
+// variantKey is the key of the thumbnail of the width, next to the picture.
+func variantKey(key string, width int) string {
+	return fmt.Sprintf("%s.w%d.jpg", strings.TrimSuffix(key, ".jpg"), width)
+}
+
+// etag is a strong validator of the key, the content of a key never changes.
+func etag(key string) string {
+	return `"` + strings.TrimSuffix(key, ".jpg") + `"`
+}
+
+// thumbnail scales the picture down to the width keeping the aspect ratio,
+// narrower pictures are not scaled up.
+func thumbnail(r io.Reader, width int) ([]byte, error) {
+	img, err := jpeg.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode jpeg: %w", err)
+	}
+
+	bounds := img.Bounds()
+
+	if bounds.Dx() > width {
+		height := max(bounds.Dy()*width/bounds.Dx(), 1)
+
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+		img = dst
+	}
+
+	var buf bytes.Buffer
+
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
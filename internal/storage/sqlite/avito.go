@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+)
+
+var (
+	avitoFeedTable           = "avito_feed"
+	avitoFeedProductIdColumn = "product_id"
+	avitoFeedPayloadColumn   = "payload"
+)
+
+// AddToAvitoFeed puts the product to the Autoload feed or replaces it there.
+func (s *Storage) AddToAvitoFeed(ctx context.Context, product *models.Product) error {
+	payload, err := json.Marshal(product)
+	if err != nil {
+		return fmt.Errorf("failed to marshal product: %w", err)
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %[1]s(%[2]s, %[3]s) VALUES (?, ?)
+		ON CONFLICT(%[2]s) DO UPDATE SET %[3]s = excluded.%[3]s`,
+		avitoFeedTable,
+		avitoFeedProductIdColumn,
+		avitoFeedPayloadColumn,
+	)
+
+	_, err = s.db.ExecContext(ctx, query, product.Id, string(payload))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveFromAvitoFeed(ctx context.Context, productID int64) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", avitoFeedTable, avitoFeedProductIdColumn)
+
+	_, err := s.db.ExecContext(ctx, query, productID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) InAvitoFeed(ctx context.Context, productID int64) (bool, error) {
+	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s = ?)", avitoFeedTable, avitoFeedProductIdColumn)
+
+	var exists bool
+
+	err := s.db.QueryRowContext(ctx, query, productID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to make query:%w", err)
+	}
+
+	return exists, nil
+}
+
+func (s *Storage) AvitoFeed(ctx context.Context) ([]*models.Product, error) {
+	query := fmt.Sprintf(
+		"SELECT %s, %s FROM %s ORDER BY %s",
+		avitoFeedProductIdColumn,
+		avitoFeedPayloadColumn,
+		avitoFeedTable,
+		avitoFeedProductIdColumn,
+	)
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	var products []*models.Product
+
+	for rows.Next() {
+		var (
+			id      int64
+			payload string
+		)
+
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, fmt.Errorf("failed to scan feed item: %w", err)
+		}
+
+		var p models.Product
+		if err := json.Unmarshal([]byte(payload), &p); err != nil {
+			s.log.Error("invalid avito feed item", "productID", id, "err", err.Error())
+			continue
+		}
+
+		p.Id = id
+
+		products = append(products, &p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return products, nil
+}
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+)
+
+// exportBatchSize is the number of products loaded at once by EachProduct.
+const exportBatchSize = 100
+
+// EachProduct calls fn for every product in the order of IDs. Products are
+// loaded in batches, so the whole catalogue is never held in memory.
+func (s *Storage) EachProduct(ctx context.Context, fn func(p *models.Product) error) error {
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s > ? AND %s IS NULL ORDER BY %s LIMIT ?",
+		productsIdColumn,
+		productsTable,
+		productsIdColumn,
+		productsDeletedAtColumn,
+		productsIdColumn,
+	)
+
+	var lastID int64
+
+	for {
+		ids, err := s.productIDs(ctx, query, lastID)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			p, err := product(ctx, s.db, id)
+			if err != nil {
+				return err
+			}
+
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < exportBatchSize {
+			return nil
+		}
+
+		lastID = ids[len(ids)-1]
+	}
+}
+
+func (s *Storage) productIDs(ctx context.Context, query string, after int64) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, query, after, exportBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, exportBatchSize)
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,145 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/storage"
+)
+
+var (
+	importBatchesTable      = "import_batches"
+	importBatchesId         = "id"
+	importBatchesFilename   = "filename"
+	importBatchesFormat     = "format"
+	importBatchesStatus     = "status"
+	importBatchesTotal      = "total"
+	importBatchesValid      = "valid"
+	importBatchesImported   = "imported"
+	importBatchesFailed     = "failed"
+	importBatchesRows       = "rows"
+	importBatchesCreatedAt  = "created_at"
+	importBatchesUpdatedAt  = "updated_at"
+	importBatchesStatFields = fmt.Sprintf("%s, %s, %s, %s, %s, %s, %s",
+		importBatchesStatus, importBatchesTotal, importBatchesValid, importBatchesImported, importBatchesFailed,
+		importBatchesRows, importBatchesUpdatedAt)
+)
+
+// SaveImportBatch creates the batch and sets its ID.
+func (s *Storage) SaveImportBatch(ctx context.Context, batch *models.ImportBatch) error {
+	rows, err := json.Marshal(batch.Rows)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rows: %w", err)
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s(%s, %s, %s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		importBatchesTable,
+		importBatchesFilename, importBatchesFormat, importBatchesStatus, importBatchesTotal,
+		importBatchesValid, importBatchesImported, importBatchesFailed, importBatchesRows,
+	)
+
+	result, err := s.db.ExecContext(ctx, query,
+		batch.Filename, batch.Format, batch.Status, batch.Total,
+		batch.Valid, batch.Imported, batch.Failed, string(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	batch.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("%w:%w", storage.ErrReturnId, err)
+	}
+
+	return nil
+}
+
+// UpdateImportBatch saves the status, counters and rows of the batch.
+func (s *Storage) UpdateImportBatch(ctx context.Context, batch *models.ImportBatch) error {
+	rows, err := json.Marshal(batch.Rows)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rows: %w", err)
+	}
+
+	query := fmt.Sprintf(
+		`UPDATE %s SET (%s) = (?, ?, ?, ?, ?, ?, datetime('now')) WHERE %s = ?`,
+		importBatchesTable,
+		importBatchesStatFields,
+		importBatchesId,
+	)
+
+	result, err := s.db.ExecContext(ctx, query,
+		batch.Status, batch.Total, batch.Valid, batch.Imported, batch.Failed, string(rows),
+		batch.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	if n == 0 {
+		return storage.ErrImportBatchNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) ImportBatch(ctx context.Context, batchID int64) (*models.ImportBatch, error) {
+	query := fmt.Sprintf(
+		`SELECT %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s FROM %s WHERE %s = ?`,
+		importBatchesId, importBatchesFilename, importBatchesFormat, importBatchesStatus,
+		importBatchesTotal, importBatchesValid, importBatchesImported, importBatchesFailed,
+		importBatchesRows, importBatchesCreatedAt, importBatchesUpdatedAt,
+		importBatchesTable,
+		importBatchesId,
+	)
+
+	var batch models.ImportBatch
+	var rows string
+
+	err := s.db.QueryRowContext(ctx, query, batchID).Scan(
+		&batch.ID, &batch.Filename, &batch.Format, &batch.Status,
+		&batch.Total, &batch.Valid, &batch.Imported, &batch.Failed,
+		&rows, &batch.CreatedAt, &batch.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrImportBatchNotFound
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	if err := json.Unmarshal([]byte(rows), &batch.Rows); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rows: %w", err)
+	}
+
+	return &batch, nil
+}
+
+// InterruptImports marks the batches left in processing by a previous run as
+// interrupted, nothing adds their rows anymore.
+func (s *Storage) InterruptImports(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = datetime('now') WHERE %s = ?",
+		importBatchesTable,
+		importBatchesStatus,
+		importBatchesUpdatedAt,
+		importBatchesStatus,
+	)
+
+	result, err := s.db.ExecContext(ctx, query, models.ImportStatusInterrupted, models.ImportStatusProcessing)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return result.RowsAffected()
+}
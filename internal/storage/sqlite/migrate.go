@@ -0,0 +1,349 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"prodLoaderREST/internal/storage"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var (
+	ErrSchemaTooNew     = errors.New("database schema is newer than the application")
+	ErrUnknownMigration = errors.New("unknown migration version")
+)
+
+var (
+	schemaMigrationsTable     = "schema_migrations"
+	schemaMigrationsVersion   = "version"
+	schemaMigrationsName      = "name"
+	schemaMigrationsAppliedAt = "applied_at"
+)
+
+// migration is a pair of NNNN_name.up.sql and NNNN_name.down.sql files.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// Migrator applies the embedded migrations, every migration runs in its own
+// transaction together with its record in schema_migrations.
+type Migrator struct {
+	log        *slog.Logger
+	db         *sql.DB
+	migrations []migration
+}
+
+// NewMigrator opens the database for the migrate command, it does not apply anything.
+func NewMigrator(log *slog.Logger, storagePath string) (*Migrator, error) {
+	if storagePath == "" {
+		return nil, fmt.Errorf("storage path is empty")
+	}
+
+	db, err := sql.Open("sqlite3", storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMigrator(log, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func newMigrator(log *slog.Logger, db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	m := &Migrator{
+		log:        log,
+		db:         db,
+		migrations: migrations,
+	}
+
+	if err := m.init(); err != nil {
+		return nil, fmt.Errorf("failed to create %s table: %w", schemaMigrationsTable, err)
+	}
+
+	return m, nil
+}
+
+// init creates the migrations table. A database created before migrations
+// existed already has the baseline schema, so it is recorded as applied.
+func (m *Migrator) init() error {
+	var exists bool
+
+	err := m.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, schemaMigrationsTable).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	var legacy bool
+
+	err = m.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, productsTable).Scan(&legacy)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.Exec(fmt.Sprintf(`
+	CREATE TABLE %s(
+		%s INTEGER PRIMARY KEY,
+		%s TEXT NOT NULL,
+		%s TEXT DEFAULT (datetime('now'))
+	)`,
+		schemaMigrationsTable,
+		schemaMigrationsVersion,
+		schemaMigrationsName,
+		schemaMigrationsAppliedAt,
+	))
+	if err != nil {
+		return err
+	}
+
+	if legacy && len(m.migrations) > 0 {
+		baseline := m.migrations[0]
+
+		m.log.Info("existing database adopted at baseline schema", "version", baseline.version)
+
+		return m.record(m.db, baseline)
+	}
+
+	return nil
+}
+
+// Version returns the version of the last applied migration, 0 for an empty database.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version int
+
+	query := fmt.Sprintf("SELECT COALESCE(MAX(%s), 0) FROM %s", schemaMigrationsVersion, schemaMigrationsTable)
+
+	err := m.db.QueryRowContext(ctx, query).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return version, nil
+}
+
+// Latest returns the version of the newest migration known to the application.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].version
+}
+
+// Check returns ErrSchemaTooNew when the database was migrated by a newer application.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if version > m.Latest() {
+		return fmt.Errorf("%w: database version %d, latest known %d", ErrSchemaTooNew, version, m.Latest())
+	}
+
+	return nil
+}
+
+// Up applies migrations up to the target version, 0 means the latest one.
+func (m *Migrator) Up(ctx context.Context, target int) error {
+	if err := m.Check(ctx); err != nil {
+		return err
+	}
+
+	if target == 0 {
+		target = m.Latest()
+	}
+
+	if err := m.known(target); err != nil {
+		return err
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range m.migrations {
+		if mig.version <= version || mig.version > target {
+			continue
+		}
+
+		if err := m.apply(ctx, mig, mig.up, true); err != nil {
+			return err
+		}
+
+		m.log.Info("migration applied", "version", mig.version, "name", mig.name)
+	}
+
+	return nil
+}
+
+// Down rolls migrations back until the target version is the last applied one,
+// 0 rolls back every migration.
+func (m *Migrator) Down(ctx context.Context, target int) error {
+	if err := m.Check(ctx); err != nil {
+		return err
+	}
+
+	if target != 0 {
+		if err := m.known(target); err != nil {
+			return err
+		}
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+
+		if mig.version > version || mig.version <= target {
+			continue
+		}
+
+		if err := m.apply(ctx, mig, mig.down, false); err != nil {
+			return err
+		}
+
+		m.log.Info("migration rolled back", "version", mig.version, "name", mig.name)
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, mig migration, script string, up bool) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", mig.version, mig.name, err)
+	}
+
+	if up {
+		err = m.record(tx, mig)
+	} else {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", schemaMigrationsTable, schemaMigrationsVersion), mig.version)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+func (m *Migrator) record(ex execer, mig migration) error {
+	query := fmt.Sprintf("INSERT INTO %s(%s, %s) VALUES (?, ?)", schemaMigrationsTable, schemaMigrationsVersion, schemaMigrationsName)
+
+	_, err := ex.ExecContext(context.Background(), query, mig.version, mig.name)
+
+	return err
+}
+
+func (m *Migrator) known(version int) error {
+	for _, mig := range m.migrations {
+		if mig.version == version {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+}
+
+func loadMigrations() ([]migration, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+
+	for _, file := range files {
+		base := strings.TrimPrefix(file, "migrations/")
+
+		name, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name: %s", base)
+		}
+
+		versionPart, name, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", base)
+		}
+
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration version: %s", base)
+		}
+
+		script, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{version: version, name: name}
+			byVersion[version] = mig
+		}
+
+		if direction == "up" {
+			mig.up = string(script)
+		} else {
+			mig.down = string(script)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+
+	for _, mig := range byVersion {
+		if mig.up == "" || mig.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have up and down files", mig.version, mig.name)
+		}
+
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS products_ad;
+DROP TRIGGER IF EXISTS products_au;
+DROP TRIGGER IF EXISTS products_ai;
+DROP TABLE IF EXISTS products_fts;
+DROP TABLE IF EXISTS product_platforms_ids;
+DROP TABLE IF EXISTS products;
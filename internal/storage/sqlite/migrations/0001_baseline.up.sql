@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS products(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    price INTEGER NOT NULL,
+    description TEXT,
+    ucoz_loaded BOOLEAN NOT NULL DEFAULT FALSE,
+    vk_loaded BOOLEAN NOT NULL DEFAULT FALSE,
+    avito_loaded BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TEXT DEFAULT (datetime('now'))
+);
+
+CREATE TABLE IF NOT EXISTS product_platforms_ids(
+    product_id INTEGER PRIMARY KEY,
+    vk_product_id INTEGER,
+    ucoz_product_id INTEGER,
+    avito_product_id INTEGER,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS products_fts
+USING fts4(
+    title,
+    ucoz_loaded,
+    vk_loaded,
+    avito_loaded,
+    content='products',
+    tokenize='simple'
+);
+
+CREATE TRIGGER IF NOT EXISTS products_ai AFTER INSERT ON products BEGIN
+    INSERT INTO products_fts(rowid, title, ucoz_loaded, vk_loaded, avito_loaded)
+    VALUES (new.id, new.title, new.ucoz_loaded, new.vk_loaded, new.avito_loaded);
+END;
+
+CREATE TRIGGER IF NOT EXISTS products_au AFTER UPDATE OF name ON products BEGIN
+    UPDATE products_fts SET
+        title = new.title,
+        ucoz_loaded = new.ucoz_loaded,
+        vk_loaded = new.vk_loaded,
+        avito_loaded = new.avito_loaded
+    WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS products_ad AFTER DELETE ON products BEGIN
+    DELETE FROM products_fts WHERE rowid = old.id;
+END;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS avito_feed(
+    product_id INTEGER PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at TEXT DEFAULT (datetime('now')),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1,16 @@
+DROP TRIGGER IF EXISTS products_bu;
+DROP TRIGGER IF EXISTS products_au;
+DROP TRIGGER IF EXISTS products_bd;
+
+CREATE TRIGGER products_au AFTER UPDATE OF name ON products BEGIN
+    UPDATE products_fts SET
+        title = new.title,
+        ucoz_loaded = new.ucoz_loaded,
+        vk_loaded = new.vk_loaded,
+        avito_loaded = new.avito_loaded
+    WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER products_ad AFTER DELETE ON products BEGIN
+    DELETE FROM products_fts WHERE rowid = old.id;
+END;
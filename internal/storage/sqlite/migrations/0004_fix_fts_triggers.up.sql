@@ -0,0 +1,22 @@
+-- the baseline update trigger watched a column that does not exist, so the
+-- index was never updated. An external content fts table needs the old row to
+-- remove it from the index: it is removed before the change and added back after it.
+DROP TRIGGER IF EXISTS products_au;
+DROP TRIGGER IF EXISTS products_ad;
+DROP TRIGGER IF EXISTS products_bu;
+DROP TRIGGER IF EXISTS products_bd;
+
+CREATE TRIGGER products_bu BEFORE UPDATE ON products BEGIN
+    DELETE FROM products_fts WHERE docid = old.id;
+END;
+
+CREATE TRIGGER products_au AFTER UPDATE ON products BEGIN
+    INSERT INTO products_fts(docid, title, ucoz_loaded, vk_loaded, avito_loaded)
+    VALUES (new.id, new.title, new.ucoz_loaded, new.vk_loaded, new.avito_loaded);
+END;
+
+CREATE TRIGGER products_bd BEFORE DELETE ON products BEGIN
+    DELETE FROM products_fts WHERE docid = old.id;
+END;
+
+INSERT INTO products_fts(products_fts) VALUES('rebuild');
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS product_images;
+
+ALTER TABLE products DROP COLUMN avito_to_load;
+ALTER TABLE products DROP COLUMN ucoz_to_load;
+ALTER TABLE products DROP COLUMN vk_to_load;
+ALTER TABLE products DROP COLUMN ucoz_category_id;
+ALTER TABLE products DROP COLUMN vk_category_id;
+ALTER TABLE products DROP COLUMN status;
+ALTER TABLE products DROP COLUMN size;
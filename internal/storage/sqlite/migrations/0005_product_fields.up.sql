@@ -0,0 +1,19 @@
+ALTER TABLE products ADD COLUMN size TEXT NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN status TEXT NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN vk_category_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN ucoz_category_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN vk_to_load BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE products ADD COLUMN ucoz_to_load BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE products ADD COLUMN avito_to_load BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- position 0 is the main picture of the product
+CREATE TABLE IF NOT EXISTS product_images(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    url TEXT NOT NULL DEFAULT '',
+    telegram_file_id TEXT,
+    telegram_url TEXT,
+    UNIQUE (product_id, position),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS outbox_product_idx ON outbox(product_id, platform, id);
@@ -0,0 +1,9 @@
+-- stage is the progress of a job reported by the consumer
+ALTER TABLE outbox ADD COLUMN stage TEXT NOT NULL DEFAULT 'queued';
+
+UPDATE outbox SET stage = CASE
+    WHEN status = 'done' AND action = 'delete' THEN 'unpublished'
+    WHEN status = 'done' THEN 'published'
+    WHEN status = 'dead' THEN 'failed'
+    ELSE status
+END;
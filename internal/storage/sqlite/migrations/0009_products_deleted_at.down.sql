@@ -0,0 +1,6 @@
+-- deleted products are gone for good without the column
+DELETE FROM product_images WHERE product_id IN (SELECT id FROM products WHERE deleted_at IS NOT NULL);
+DELETE FROM product_platforms_ids WHERE product_id IN (SELECT id FROM products WHERE deleted_at IS NOT NULL);
+DELETE FROM products WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE products DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+-- deleted_at is set when the product is deleted. The row is never purged: its
+-- jobs refer to it and the reconciliation knows the items of deleted products by it
+ALTER TABLE products ADD COLUMN deleted_at TEXT;
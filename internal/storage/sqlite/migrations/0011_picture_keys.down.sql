@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS product_images_picture_key_idx;
+
+ALTER TABLE product_images DROP COLUMN picture_key;
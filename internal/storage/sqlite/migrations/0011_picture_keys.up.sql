@@ -0,0 +1,4 @@
+-- picture_key is the key of the normalized picture in the picture store
+ALTER TABLE product_images ADD COLUMN picture_key TEXT;
+
+CREATE INDEX IF NOT EXISTS product_images_picture_key_idx ON product_images(picture_key);
@@ -0,0 +1 @@
+ALTER TABLE product_platforms_ids DROP COLUMN vk_name;
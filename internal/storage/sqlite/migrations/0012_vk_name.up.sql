@@ -0,0 +1,3 @@
+-- vk_name is the name the product was last published with on the VK market,
+-- it's not always the title: adopted items keep the name they had there
+ALTER TABLE product_platforms_ids ADD COLUMN vk_name TEXT;
@@ -0,0 +1,498 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/storage"
+	"strings"
+	"time"
+)
+
+var (
+	outboxTable              = "outbox"
+	outboxIdColumn           = "id"
+	outboxProductIdColumn    = "product_id"
+	outboxPlatformColumn     = "platform"
+	outboxActionColumn       = "action"
+	outboxPayloadColumn      = "payload"
+	outboxStatusColumn       = "status"
+	outboxStageColumn        = "stage"
+	outboxAttemptsColumn     = "attempts"
+	outboxLastErrorColumn    = "last_error"
+	outboxLeasedUntilColumn  = "leased_until"
+	outboxAvailableAtColumn  = "available_at"
+	outboxCreatedAtColumn    = "created_at"
+	outboxUpdatedAtColumn    = "updated_at"
+	outboxReturningJobFields = fmt.Sprintf("%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s",
+		outboxIdColumn, outboxProductIdColumn, outboxPlatformColumn, outboxActionColumn, outboxPayloadColumn,
+		outboxStatusColumn, outboxStageColumn, outboxAttemptsColumn, outboxLastErrorColumn, outboxAvailableAtColumn,
+		outboxCreatedAtColumn, outboxUpdatedAtColumn)
+)
+
+// leaseHolder matches a job processed under the lease of the attempt. Every
+// lease increments the attempts, so once the lease expires and the job is
+// leased again, the previous holder can't change its state.
+var leaseHolder = fmt.Sprintf("%s = ? AND %s = ? AND %s = ?", outboxIdColumn, outboxStatusColumn, outboxAttemptsColumn)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertJob(ctx context.Context, ex execer, job *models.Job) (int64, error) {
+	query := fmt.Sprintf(
+		`INSERT INTO %s(%s, %s, %s, %s) VALUES (?, ?, ?, ?)`,
+		outboxTable,
+		outboxProductIdColumn,
+		outboxPlatformColumn,
+		outboxActionColumn,
+		outboxPayloadColumn,
+	)
+
+	result, err := ex.ExecContext(ctx, query, job.ProductID, job.Platform, job.Action, job.Payload)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%w:%w", storage.ErrReturnId, err)
+	}
+
+	job.Status = models.JobStatusQueued
+	job.Stage = models.JobStageQueued
+
+	return id, nil
+}
+
+// cancelJobs cancels the queued add and update jobs of the product on the
+// platforms, on every platform when none are given.
+func cancelJobs(ctx context.Context, ex execer, productID int64, platforms []string, reason string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ?, %s = ?, %s = datetime('now') WHERE %s = ? AND %s = ? AND %s IN (?, ?)",
+		outboxTable,
+		outboxStatusColumn,
+		outboxStageColumn,
+		outboxLastErrorColumn,
+		outboxUpdatedAtColumn,
+		outboxProductIdColumn,
+		outboxStatusColumn,
+		outboxActionColumn,
+	)
+
+	args := []any{
+		models.JobStatusCancelled, models.JobStageCancelled, reason,
+		productID, models.JobStatusQueued, models.JobActionAdd, models.JobActionUpdate,
+	}
+
+	if len(platforms) > 0 {
+		query += fmt.Sprintf(" AND %s IN (%s)", outboxPlatformColumn, placeholders(len(platforms)))
+
+		for _, platform := range platforms {
+			args = append(args, platform)
+		}
+	}
+
+	_, err := ex.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) EnqueueJob(ctx context.Context, job *models.Job) (int64, error) {
+	id, err := insertJob(ctx, s.db, job)
+	if err != nil {
+		return 0, err
+	}
+
+	job.ID = id
+
+	return id, nil
+}
+
+// EnqueueWithdraw enqueues the delete job of a product published while it was
+// deleted or unpublished, unless a delete job of the product on the platform
+// is already pending. It reports whether the job is enqueued.
+func (s *Storage) EnqueueWithdraw(ctx context.Context, job *models.Job) (bool, error) {
+	query := fmt.Sprintf(`
+	INSERT INTO %[1]s(%[2]s, %[3]s, %[4]s, %[5]s)
+	SELECT ?, ?, ?, ?
+	WHERE NOT EXISTS (
+		SELECT 1 FROM %[1]s
+		WHERE %[2]s = ? AND %[3]s = ? AND %[4]s = ? AND %[6]s IN (?, ?)
+	)`,
+		outboxTable,
+		outboxProductIdColumn,
+		outboxPlatformColumn,
+		outboxActionColumn,
+		outboxPayloadColumn,
+		outboxStatusColumn,
+	)
+
+	result, err := s.db.ExecContext(ctx, query,
+		job.ProductID, job.Platform, job.Action, job.Payload,
+		job.ProductID, job.Platform, job.Action, models.JobStatusQueued, models.JobStatusProcessing,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	if n == 0 {
+		return false, nil
+	}
+
+	job.ID, err = result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("%w:%w", storage.ErrReturnId, err)
+	}
+
+	job.Status = models.JobStatusQueued
+	job.Stage = models.JobStageQueued
+
+	return true, nil
+}
+
+// LeaseJobs marks up to limit queued jobs of the platforms that are due (and
+// jobs whose lease has expired) as processing for the lease duration and returns them.
+func (s *Storage) LeaseJobs(ctx context.Context, platforms []string, limit int, lease time.Duration) ([]*models.Job, error) {
+	if len(platforms) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+	UPDATE %[1]s
+	SET %[2]s = ?, %[11]s = ?, %[3]s = %[3]s + 1, %[4]s = datetime('now', ?), %[5]s = datetime('now')
+	WHERE %[6]s IN (
+		SELECT %[6]s FROM %[1]s
+		WHERE %[9]s IN (%[10]s)
+		AND (
+			(%[2]s = ? AND (%[8]s IS NULL OR %[8]s <= datetime('now')))
+			OR (%[2]s = ? AND %[4]s <= datetime('now'))
+		)
+		ORDER BY %[6]s
+		LIMIT ?
+	)
+	RETURNING %[7]s`,
+		outboxTable,
+		outboxStatusColumn,
+		outboxAttemptsColumn,
+		outboxLeasedUntilColumn,
+		outboxUpdatedAtColumn,
+		outboxIdColumn,
+		outboxReturningJobFields,
+		outboxAvailableAtColumn,
+		outboxPlatformColumn,
+		placeholders(len(platforms)),
+		outboxStageColumn,
+	)
+
+	args := []any{models.JobStatusProcessing, models.JobStageProcessing, secondsModifier(lease)}
+	for _, platform := range platforms {
+		args = append(args, platform)
+	}
+	args = append(args, models.JobStatusQueued, models.JobStatusProcessing, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.Job
+
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return jobs, nil
+}
+
+// CompleteJob marks the job as done, the stage is unpublished for a delete
+// and published for the other actions.
+func (s *Storage) CompleteJob(ctx context.Context, jobID int64, attempt int) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = CASE %s WHEN ? THEN ? ELSE ? END, %s = '', %s = NULL, %s = datetime('now') WHERE %s",
+		outboxTable,
+		outboxStatusColumn,
+		outboxStageColumn,
+		outboxActionColumn,
+		outboxLastErrorColumn,
+		outboxLeasedUntilColumn,
+		outboxUpdatedAtColumn,
+		leaseHolder,
+	)
+
+	return s.execLeasedJobUpdate(ctx, query,
+		models.JobStatusDone,
+		models.JobActionDelete, models.JobStageUnpublished, models.JobStagePublished,
+		jobID, models.JobStatusProcessing, attempt,
+	)
+}
+
+// SetJobStage saves the progress of a job while it's processed and extends
+// its lease, a job that makes progress is not handed to another worker.
+func (s *Storage) SetJobStage(ctx context.Context, jobID int64, attempt int, stage string, lease time.Duration) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = datetime('now', ?), %s = datetime('now') WHERE %s",
+		outboxTable,
+		outboxStageColumn,
+		outboxLeasedUntilColumn,
+		outboxUpdatedAtColumn,
+		leaseHolder,
+	)
+
+	return s.execLeasedJobUpdate(ctx, query, stage, secondsModifier(lease), jobID, models.JobStatusProcessing, attempt)
+}
+
+// RetryJob puts the job back to the queue, it becomes available after delay.
+func (s *Storage) RetryJob(ctx context.Context, jobID int64, attempt int, reason string, delay time.Duration) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ?, %s = ?, %s = NULL, %s = datetime('now', ?), %s = datetime('now') WHERE %s",
+		outboxTable,
+		outboxStatusColumn,
+		outboxStageColumn,
+		outboxLastErrorColumn,
+		outboxLeasedUntilColumn,
+		outboxAvailableAtColumn,
+		outboxUpdatedAtColumn,
+		leaseHolder,
+	)
+
+	return s.execLeasedJobUpdate(ctx, query,
+		models.JobStatusQueued, models.JobStageQueued, reason, secondsModifier(delay),
+		jobID, models.JobStatusProcessing, attempt,
+	)
+}
+
+// BuryJob moves the job to the dead-letter list.
+func (s *Storage) BuryJob(ctx context.Context, jobID int64, attempt int, reason string) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ?, %s = ?, %s = NULL, %s = datetime('now') WHERE %s",
+		outboxTable,
+		outboxStatusColumn,
+		outboxStageColumn,
+		outboxLastErrorColumn,
+		outboxLeasedUntilColumn,
+		outboxUpdatedAtColumn,
+		leaseHolder,
+	)
+
+	return s.execLeasedJobUpdate(ctx, query,
+		models.JobStatusDead, models.JobStageFailed, reason,
+		jobID, models.JobStatusProcessing, attempt,
+	)
+}
+
+// RedriveJob returns a dead job to the queue with a fresh attempt counter.
+func (s *Storage) RedriveJob(ctx context.Context, jobID int64) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ?, %s = 0, %s = NULL, %s = NULL, %s = datetime('now') WHERE %s = ? AND %s = ?",
+		outboxTable,
+		outboxStatusColumn,
+		outboxStageColumn,
+		outboxAttemptsColumn,
+		outboxLeasedUntilColumn,
+		outboxAvailableAtColumn,
+		outboxUpdatedAtColumn,
+		outboxIdColumn,
+		outboxStatusColumn,
+	)
+
+	return s.execJobUpdate(ctx, query, models.JobStatusQueued, models.JobStageQueued, jobID, models.JobStatusDead)
+}
+
+func (s *Storage) DeadJobs(ctx context.Context, offset int, limit int) (jobs []*models.Job, count int, err error) {
+	queryCount := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", outboxTable, outboxStatusColumn)
+
+	err = s.db.QueryRowContext(ctx, queryCount, models.JobStatusDead).Scan(&count)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead jobs: %w", err)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s = ? ORDER BY %s DESC LIMIT ? OFFSET ?",
+		outboxReturningJobFields,
+		outboxTable,
+		outboxStatusColumn,
+		outboxIdColumn,
+	)
+
+	rows, err := s.db.QueryContext(ctx, query, models.JobStatusDead, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return jobs, count, nil
+}
+
+func (s *Storage) Job(ctx context.Context, jobID int64) (*models.Job, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", outboxReturningJobFields, outboxTable, outboxIdColumn)
+
+	job, err := scanJob(s.db.QueryRowContext(ctx, query, jobID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrJobNotFound
+		}
+
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// ProductJobs returns every job of the product, the latest first.
+func (s *Storage) ProductJobs(ctx context.Context, productID int64) ([]*models.Job, error) {
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s = ? ORDER BY %s DESC",
+		outboxReturningJobFields,
+		outboxTable,
+		outboxProductIdColumn,
+		outboxIdColumn,
+	)
+
+	rows, err := s.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	jobs := make([]*models.Job, 0)
+
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return jobs, nil
+}
+
+// RequeueJobs returns jobs left in processing by a previous run back to the queue.
+func (s *Storage) RequeueJobs(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ?, %s = NULL, %s = datetime('now') WHERE %s = ?",
+		outboxTable,
+		outboxStatusColumn,
+		outboxStageColumn,
+		outboxLeasedUntilColumn,
+		outboxUpdatedAtColumn,
+		outboxStatusColumn,
+	)
+
+	result, err := s.db.ExecContext(ctx, query, models.JobStatusQueued, models.JobStageQueued, models.JobStatusProcessing)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *Storage) execJobUpdate(ctx context.Context, query string, args ...any) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	if n == 0 {
+		return storage.ErrJobNotFound
+	}
+
+	return nil
+}
+
+// execLeasedJobUpdate is execJobUpdate for the holder of a lease, no row is
+// updated when the job is missing or leased again.
+func (s *Storage) execLeasedJobUpdate(ctx context.Context, query string, args ...any) error {
+	err := s.execJobUpdate(ctx, query, args...)
+	if errors.Is(err, storage.ErrJobNotFound) {
+		return storage.ErrJobLeaseLost
+	}
+
+	return err
+}
+
+// placeholders returns n comma separated bind parameters.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// secondsModifier formats d as an SQLite datetime modifier.
+func secondsModifier(d time.Duration) string {
+	return fmt.Sprintf("+%d seconds", int(d.Seconds()))
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row scanner) (*models.Job, error) {
+	var job models.Job
+
+	var availableAt sql.NullString
+
+	err := row.Scan(
+		&job.ID,
+		&job.ProductID,
+		&job.Platform,
+		&job.Action,
+		&job.Payload,
+		&job.Status,
+		&job.Stage,
+		&job.Attempts,
+		&job.LastError,
+		&availableAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan job: %w", err)
+	}
+
+	job.AvailableAt = availableAt.String
+
+	return &job, nil
+}
@@ -0,0 +1,316 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/storage"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	s, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func enqueue(t *testing.T, s *Storage, productID int64, platform string, action string) int64 {
+	t.Helper()
+
+	id, err := s.EnqueueJob(context.Background(), &models.Job{ProductID: productID, Platform: platform, Action: action})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func lease(t *testing.T, s *Storage, platform string) []*models.Job {
+	t.Helper()
+
+	jobs, err := s.LeaseJobs(context.Background(), []string{platform}, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return jobs
+}
+
+// expireLease moves the lease of the job to the past, as if its holder hung.
+func expireLease(t *testing.T, s *Storage, jobID int64) {
+	t.Helper()
+
+	_, err := s.db.Exec("UPDATE outbox SET leased_until = datetime('now', '-1 seconds') WHERE id = ?", jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func job(t *testing.T, s *Storage, jobID int64) *models.Job {
+	t.Helper()
+
+	j, err := s.Job(context.Background(), jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return j
+}
+
+func TestLeaseJobs(t *testing.T) {
+	s := newTestStorage(t)
+
+	vk := enqueue(t, s, 1, models.PlatformVK, models.JobActionAdd)
+	enqueue(t, s, 1, models.PlatformUcoz, models.JobActionAdd)
+
+	jobs := lease(t, s, models.PlatformVK)
+	if len(jobs) != 1 || jobs[0].ID != vk {
+		t.Fatalf("expected the vk job leased, got %v", jobs)
+	}
+
+	if jobs[0].Status != models.JobStatusProcessing || jobs[0].Attempts != 1 {
+		t.Fatalf("expected processing on attempt 1, got %s on %d", jobs[0].Status, jobs[0].Attempts)
+	}
+
+	// a leased job is not leased again while its lease holds
+	if jobs := lease(t, s, models.PlatformVK); len(jobs) != 0 {
+		t.Fatalf("expected nothing to lease, got %v", jobs)
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id := enqueue(t, s, 1, models.PlatformVK, models.JobActionAdd)
+
+	first := lease(t, s, models.PlatformVK)[0]
+
+	expireLease(t, s, id)
+
+	jobs := lease(t, s, models.PlatformVK)
+	if len(jobs) != 1 || jobs[0].Attempts != 2 {
+		t.Fatalf("expected the expired job leased on attempt 2, got %v", jobs)
+	}
+
+	second := jobs[0]
+
+	// the first holder lost the job, nothing it does changes the job
+	if err := s.SetJobStage(ctx, id, first.Attempts, models.JobStagePublishing, time.Minute); !errors.Is(err, storage.ErrJobLeaseLost) {
+		t.Fatalf("expected lost lease on stage, got %v", err)
+	}
+
+	if err := s.CompleteJob(ctx, id, first.Attempts); !errors.Is(err, storage.ErrJobLeaseLost) {
+		t.Fatalf("expected lost lease on complete, got %v", err)
+	}
+
+	if err := s.RetryJob(ctx, id, first.Attempts, "timeout", time.Minute); !errors.Is(err, storage.ErrJobLeaseLost) {
+		t.Fatalf("expected lost lease on retry, got %v", err)
+	}
+
+	if err := s.BuryJob(ctx, id, first.Attempts, "timeout"); !errors.Is(err, storage.ErrJobLeaseLost) {
+		t.Fatalf("expected lost lease on bury, got %v", err)
+	}
+
+	if j := job(t, s, id); j.Status != models.JobStatusProcessing || j.Stage != models.JobStageProcessing {
+		t.Fatalf("job changed by the first holder: %s %s", j.Status, j.Stage)
+	}
+
+	if err := s.CompleteJob(ctx, id, second.Attempts); err != nil {
+		t.Fatal(err)
+	}
+
+	if j := job(t, s, id); j.Status != models.JobStatusDone || j.Stage != models.JobStagePublished {
+		t.Fatalf("expected done and published, got %s %s", j.Status, j.Stage)
+	}
+}
+
+func TestSetJobStageExtendsLease(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id := enqueue(t, s, 1, models.PlatformVK, models.JobActionAdd)
+
+	leased := lease(t, s, models.PlatformVK)[0]
+
+	expireLease(t, s, id)
+
+	// the holder is still the owner until someone else leases the job
+	if err := s.SetJobStage(ctx, id, leased.Attempts, models.JobStageUploadingPictures, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if jobs := lease(t, s, models.PlatformVK); len(jobs) != 0 {
+		t.Fatalf("expected the extended lease to hold, got %v", jobs)
+	}
+
+	if j := job(t, s, id); j.Stage != models.JobStageUploadingPictures {
+		t.Fatalf("expected stage %s, got %s", models.JobStageUploadingPictures, j.Stage)
+	}
+}
+
+func TestRequeueJobs(t *testing.T) {
+	s := newTestStorage(t)
+
+	id := enqueue(t, s, 1, models.PlatformVK, models.JobActionAdd)
+	lease(t, s, models.PlatformVK)
+
+	n, err := s.RequeueJobs(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 job requeued, got %d %v", n, err)
+	}
+
+	jobs := lease(t, s, models.PlatformVK)
+	if len(jobs) != 1 || jobs[0].ID != id || jobs[0].Attempts != 2 {
+		t.Fatalf("expected the requeued job leased on attempt 2, got %v", jobs)
+	}
+}
+
+// makeDue moves the retry time of the job to the past.
+func makeDue(t *testing.T, s *Storage, jobID int64) {
+	t.Helper()
+
+	_, err := s.db.Exec("UPDATE outbox SET available_at = datetime('now', '-1 seconds') WHERE id = ?", jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id := enqueue(t, s, 1, models.PlatformVK, models.JobActionAdd)
+	leased := lease(t, s, models.PlatformVK)[0]
+
+	if err := s.RetryJob(ctx, id, leased.Attempts, "vk is down", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	j := job(t, s, id)
+	if j.Status != models.JobStatusQueued || j.LastError != "vk is down" || j.AvailableAt == "" {
+		t.Fatalf("expected a queued job with the error and retry time, got %+v", j)
+	}
+
+	// the job waits for its backoff
+	if jobs := lease(t, s, models.PlatformVK); len(jobs) != 0 {
+		t.Fatalf("expected nothing due, got %v", jobs)
+	}
+
+	makeDue(t, s, id)
+
+	jobs := lease(t, s, models.PlatformVK)
+	if len(jobs) != 1 || jobs[0].Attempts != 2 {
+		t.Fatalf("expected the job retried on attempt 2, got %v", jobs)
+	}
+
+	if err := s.CompleteJob(ctx, id, jobs[0].Attempts); err != nil {
+		t.Fatal(err)
+	}
+
+	if j := job(t, s, id); j.Status != models.JobStatusDone || j.LastError != "" {
+		t.Fatalf("expected done with the error cleared, got %+v", j)
+	}
+}
+
+func TestBuryAndRedriveJob(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id := enqueue(t, s, 1, models.PlatformUcoz, models.JobActionAdd)
+	leased := lease(t, s, models.PlatformUcoz)[0]
+
+	if err := s.BuryJob(ctx, id, leased.Attempts, "unknown category"); err != nil {
+		t.Fatal(err)
+	}
+
+	j := job(t, s, id)
+	if j.Status != models.JobStatusDead || j.Stage != models.JobStageFailed || j.LastError != "unknown category" {
+		t.Fatalf("expected a dead job with the error, got %+v", j)
+	}
+
+	if jobs := lease(t, s, models.PlatformUcoz); len(jobs) != 0 {
+		t.Fatalf("expected a dead job not leased, got %v", jobs)
+	}
+
+	dead, count, err := s.DeadJobs(ctx, 0, 10)
+	if err != nil || count != 1 || len(dead) != 1 || dead[0].ID != id {
+		t.Fatalf("expected the job in the dead-letter list, got %v %d %v", dead, count, err)
+	}
+
+	if err := s.RedriveJob(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// only dead jobs are redriven
+	if err := s.RedriveJob(ctx, id); !errors.Is(err, storage.ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound redriving a queued job, got %v", err)
+	}
+
+	jobs := lease(t, s, models.PlatformUcoz)
+	if len(jobs) != 1 || jobs[0].Attempts != 1 {
+		t.Fatalf("expected the redriven job leased with fresh attempts, got %v", jobs)
+	}
+}
+
+func TestJobStages(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	add := enqueue(t, s, 1, models.PlatformVK, models.JobActionAdd)
+	if j := job(t, s, add); j.Stage != models.JobStageQueued {
+		t.Fatalf("expected stage %s, got %s", models.JobStageQueued, j.Stage)
+	}
+
+	leased := lease(t, s, models.PlatformVK)[0]
+	if leased.Stage != models.JobStageProcessing {
+		t.Fatalf("expected stage %s, got %s", models.JobStageProcessing, leased.Stage)
+	}
+
+	if err := s.SetJobStage(ctx, add, leased.Attempts, models.JobStagePublishing, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CompleteJob(ctx, add, leased.Attempts); err != nil {
+		t.Fatal(err)
+	}
+
+	if j := job(t, s, add); j.Stage != models.JobStagePublished {
+		t.Fatalf("expected stage %s, got %s", models.JobStagePublished, j.Stage)
+	}
+
+	// a completed delete unpublishes the product
+	del := enqueue(t, s, 1, models.PlatformVK, models.JobActionDelete)
+	if err := s.CompleteJob(ctx, del, lease(t, s, models.PlatformVK)[0].Attempts); err != nil {
+		t.Fatal(err)
+	}
+
+	if j := job(t, s, del); j.Stage != models.JobStageUnpublished {
+		t.Fatalf("expected stage %s, got %s", models.JobStageUnpublished, j.Stage)
+	}
+
+	jobs, err := s.ProductJobs(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the latest job comes first
+	if len(jobs) != 2 || jobs[0].ID != del || jobs[1].ID != add {
+		t.Fatalf("expected the delete and the add job, got %v", jobs)
+	}
+
+	if jobs, err := s.ProductJobs(ctx, 2); err != nil || len(jobs) != 0 {
+		t.Fatalf("expected no jobs of another product, got %v %v", jobs, err)
+	}
+}
@@ -0,0 +1,247 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/storage"
+)
+
+// savePictures replaces the picture URLs of the product, the main picture is
+// at position 0. Stored pictures are kept for the URLs that stay.
+func savePictures(ctx context.Context, tx *sql.Tx, productID int64, p *models.Product) error {
+	keys, err := urlPictureKeys(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", productImagesTable, productsIDkey)
+
+	_, err = tx.ExecContext(ctx, query, productID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s(%s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''))",
+		productImagesTable,
+		productsIDkey,
+		productImagesPosition,
+		productImagesUrl,
+		productImagesTelegramFileID,
+		productImagesTelegramUrl,
+		productImagesPictureKey,
+	)
+
+	telegram := make(map[string]models.TelegramPicture, len(p.Telegram))
+	for _, picture := range p.Telegram {
+		telegram[picture.URL] = picture
+	}
+
+	for position, url := range append([]string{p.MainPictureURL}, p.PicturesURL...) {
+		picture := telegram[url]
+
+		_, err = tx.ExecContext(ctx, query, productID, position, url, picture.FileID, picture.URL, keys[url])
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrExecStmt, err)
+		}
+	}
+
+	return nil
+}
+
+// urlPictureKeys returns the keys of the stored pictures of the product by URL.
+func urlPictureKeys(ctx context.Context, q queryer, productID int64) (map[string]string, error) {
+	query := fmt.Sprintf(
+		"SELECT %s, %s FROM %s WHERE %s = ? AND %s IS NOT NULL",
+		productImagesUrl,
+		productImagesPictureKey,
+		productImagesTable,
+		productsIDkey,
+		productImagesPictureKey,
+	)
+
+	rows, err := q.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	keys := make(map[string]string)
+
+	for rows.Next() {
+		var url, key string
+
+		if err := rows.Scan(&url, &key); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+		}
+
+		keys[url] = key
+	}
+
+	return keys, rows.Err()
+}
+
+func loadPictures(ctx context.Context, q queryer, p *models.Product) error {
+	query := fmt.Sprintf(
+		"SELECT %s, %s, COALESCE(%s, ''), COALESCE(%s, '') FROM %s WHERE %s = ? ORDER BY %s",
+		productImagesPosition,
+		productImagesUrl,
+		productImagesTelegramFileID,
+		productImagesTelegramUrl,
+		productImagesTable,
+		productsIDkey,
+		productImagesPosition,
+	)
+
+	rows, err := q.QueryContext(ctx, query, p.Id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	p.PicturesURL = make([]string, 0)
+	p.Telegram = nil
+
+	for rows.Next() {
+		var position int
+		var url string
+		var telegram models.TelegramPicture
+
+		if err := rows.Scan(&position, &url, &telegram.FileID, &telegram.URL); err != nil {
+			return fmt.Errorf("%w: %w", ErrExecStmt, err)
+		}
+
+		if telegram.FileID != "" {
+			p.Telegram = append(p.Telegram, telegram)
+		}
+
+		if position == 0 {
+			p.MainPictureURL = url
+			continue
+		}
+
+		p.PicturesURL = append(p.PicturesURL, url)
+	}
+
+	return rows.Err()
+}
+
+// SetPictureKeys saves the keys of the stored pictures of the product by
+// position, an empty key marks a picture that isn't stored.
+func (s *Storage) SetPictureKeys(ctx context.Context, productID int64, keys []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
+	}
+
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = NULLIF(?, '') WHERE %s = ? AND %s = ?",
+		productImagesTable,
+		productImagesPictureKey,
+		productsIDkey,
+		productImagesPosition,
+	)
+
+	for position, key := range keys {
+		_, err := tx.ExecContext(ctx, query, key, productID, position)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrExecStmt, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
+	}
+
+	return nil
+}
+
+// PictureKey returns the key of the stored picture at the position,
+// storage.ErrPictureNotFound when the product or the picture is missing.
+func (s *Storage) PictureKey(ctx context.Context, productID int64, position int) (string, error) {
+	query := fmt.Sprintf(
+		"SELECT i.%s FROM %s i JOIN %s p ON p.%s = i.%s WHERE i.%s = ? AND i.%s = ? AND i.%s IS NOT NULL AND p.%s IS NULL",
+		productImagesPictureKey,
+		productImagesTable,
+		productsTable,
+		productsIdColumn,
+		productsIDkey,
+		productsIDkey,
+		productImagesPosition,
+		productImagesPictureKey,
+		productsDeletedAtColumn,
+	)
+
+	var key string
+
+	err := s.db.QueryRowContext(ctx, query, productID, position).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrPictureNotFound
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return key, nil
+}
+
+// ClearPictureKeys forgets the stored pictures of the product and returns their keys.
+func (s *Storage) ClearPictureKeys(ctx context.Context, productID int64) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
+	}
+
+	defer tx.Rollback()
+
+	keys, err := urlPictureKeys(ctx, tx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s = ?", productImagesTable, productImagesPictureKey, productsIDkey)
+
+	_, err = tx.ExecContext(ctx, query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
+	}
+
+	unique := make(map[string]struct{}, len(keys))
+	result := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		if _, ok := unique[key]; ok {
+			continue
+		}
+
+		unique[key] = struct{}{}
+		result = append(result, key)
+	}
+
+	return result, nil
+}
+
+// PictureKeyUsed reports whether any product still has the stored picture.
+func (s *Storage) PictureKeyUsed(ctx context.Context, key string) (bool, error) {
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s = ?)", productImagesTable, productImagesPictureKey)
+
+	var used bool
+
+	if err := s.db.QueryRowContext(ctx, query, key).Scan(&used); err != nil {
+		return false, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return used, nil
+}
@@ -5,8 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"log/slog"
+	"prodLoaderREST/internal/domain/filters"
 	"prodLoaderREST/internal/domain/models"
 	"prodLoaderREST/internal/storage"
 	"strings"
@@ -19,6 +19,9 @@ var (
 	ErrPrepareStmt = fmt.Errorf("failed to prepare statement")
 )
 
+// timeLayout is the format of datetime('now') columns.
+const timeLayout = "2006-01-02 15:04:05"
+
 type Storage struct {
 	log *slog.Logger
 	db  *sql.DB
@@ -27,25 +30,43 @@ type Storage struct {
 var (
 	productsFtsTable = "products_fts"
 
-	productsTable             = "products"
-	productsIdColumn          = "id"
-	productsTitleColumm       = "title"
-	productsPriceColumn       = "price"
-	productsDescripColumn     = "description"
-	productsUcozLoadedColumn  = "ucoz_loaded"
-	productsVKLoadedColumn    = "vk_loaded"
-	productsAvitoLoadedColumn = "avito_loaded"
+	productsTable              = "products"
+	productsIdColumn           = "id"
+	productsTitleColumm        = "title"
+	productsPriceColumn        = "price"
+	productsDescripColumn      = "description"
+	productsUcozLoadedColumn   = "ucoz_loaded"
+	productsVKLoadedColumn     = "vk_loaded"
+	productsAvitoLoadedColumn  = "avito_loaded"
+	productsCreatedAtColumn    = "created_at"
+	productsSizeColumn         = "size"
+	productsStatusColumn       = "status"
+	productsVKCategoryColumn   = "vk_category_id"
+	productsUcozCategoryColumn = "ucoz_category_id"
+	productsVKToLoadColumn     = "vk_to_load"
+	productsUcozToLoadColumn   = "ucoz_to_load"
+	productsAvitoToLoadColumn  = "avito_to_load"
+	productsDeletedAtColumn    = "deleted_at"
 
 	productsIDkey = "product_id"
 
-	productsPlatformIDsTable = "product_platforms_ids"
-	productsPlatformIDsVK    = "vk_product_id"
-	productsPlatformIDsUcoz  = "ucoz_product_id"
-	productsPlatformIDsAvito = "avito_product_id"
+	productsPlatformIDsTable  = "product_platforms_ids"
+	productsPlatformIDsVK     = "vk_product_id"
+	productsPlatformIDsUcoz   = "ucoz_product_id"
+	productsPlatformIDsAvito  = "avito_product_id"
+	productsPlatformIDsVKName = "vk_name"
 
 	productImagesTable          = "product_images"
+	productImagesPosition       = "position"
+	productImagesUrl            = "url"
 	productImagesTelegramFileID = "telegram_file_id"
 	productImagesTelegramUrl    = "telegram_url"
+	productImagesPictureKey     = "picture_key"
+
+	productFields = fmt.Sprintf("%s, %s, %s, %s, %s, %s, %s, %s, %s, %s",
+		productsTitleColumm, productsPriceColumn, productsDescripColumn, productsSizeColumn, productsStatusColumn,
+		productsVKCategoryColumn, productsUcozCategoryColumn,
+		productsVKToLoadColumn, productsUcozToLoadColumn, productsAvitoToLoadColumn)
 )
 
 func New(log *slog.Logger, storagePath string) (*Storage, error) {
@@ -61,99 +82,58 @@ func New(log *slog.Logger, storagePath string) (*Storage, error) {
 		return nil, err
 	}
 
-	_, err = db.Exec(`
-    CREATE TABLE IF NOT EXISTS products(
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        title TEXT NOT NULL,
-        price INTEGER NOT NULL,
-        description TEXT,
-        ucoz_loaded BOOLEAN NOT NULL DEFAULT FALSE,
-        vk_loaded BOOLEAN NOT NULL DEFAULT FALSE,
-        avito_loaded BOOLEAN NOT NULL DEFAULT FALSE, 
-        created_at TEXT DEFAULT (datetime('now'))
-		
-    );`)
+	migrator, err := newMigrator(log, db)
 	if err != nil {
-
-		return nil, fmt.Errorf("failed to create products table: %w", err)
+		return nil, err
 	}
 
-	_, err = db.Exec(`
-    CREATE TABLE IF NOT EXISTS product_platforms_ids(
-        product_id INTEGER PRIMARY KEY,
-        vk_product_id INTEGER,
-        ucoz_product_id INTEGER,
-        avito_product_id INTEGER,
-        FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
-    );`)
+	err = migrator.Up(context.Background(), 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create product_platforms_ids table: %w", err)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	_, err = db.Exec(`
-	CREATE VIRTUAL TABLE IF NOT EXISTS products_fts 
-	USING fts4(
-	title,
-	ucoz_loaded,
-    vk_loaded,
-    avito_loaded, 
-    content='products',
-    tokenize='simple');`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create product_images table: %w", err)
-	}
+	return &Storage{log: log, db: db}, nil
+}
 
-	_, err = db.Exec(`
-	CREATE TRIGGER IF NOT EXISTS products_ai AFTER INSERT ON products BEGIN
-    INSERT INTO products_fts(rowid, title, ucoz_loaded, vk_loaded, avito_loaded)
-    VALUES (new.id, new.title, new.ucoz_loaded, new.vk_loaded, new.avito_loaded);
-	END;`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create product_images table: %w", err)
-	}
+func (s *Storage) Save(ctx context.Context, product *models.Product, jobs ...*models.Job) (int64, error) {
 
-	_, err = db.Exec(`
-	CREATE TRIGGER IF NOT EXISTS products_au AFTER UPDATE OF name ON products BEGIN
-    UPDATE products_fts SET
-        title = new.title,
-		ucoz_loaded = new.ucoz_loaded,
-		vk_loaded = new.vk_loaded, 
-		avito_loaded = new.avito_loaded
-       
-    WHERE rowid = old.id;
-END;`)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create product_images table: %w", err)
+		return 0, fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
 	}
 
-	_, err = db.Exec(`
-	CREATE TRIGGER IF NOT EXISTS products_ad AFTER DELETE ON products BEGIN
-    DELETE FROM products_fts WHERE rowid = old.id;
-END;`)
+	defer tx.Rollback()
+
+	id, err := insertProduct(ctx, tx, product)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create product_images table: %w", err)
+		return 0, err
 	}
 
-	return &Storage{log: log, db: db}, nil
-}
+	//3
+	for _, job := range jobs {
+		job.ProductID = id
 
-func (s *Storage) Save(ctx context.Context, product *models.Product) (int64, error) {
+		job.ID, err = insertJob(ctx, tx, job)
+		if err != nil {
+			return 0, err
+		}
+	}
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	err = tx.Commit()
 	if err != nil {
-		return 0, fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
+		return 0, fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
 	}
+	return id, nil
+}
 
-	defer tx.Rollback()
-
+// insertProduct inserts the product with its row of platform IDs and its pictures.
+func insertProduct(ctx context.Context, tx *sql.Tx, product *models.Product) (int64, error) {
 	product.Title = strings.ToLower(product.Title)
 
 	query := fmt.Sprintf(
-		`INSERT INTO %s(%s, %s, %s) VALUES (LOWER(?), ?, ?) RETURNING id`,
+		`INSERT INTO %s(%s) VALUES (LOWER(?), ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
 		productsTable,
-		productsTitleColumm,
-		productsPriceColumn,
-		productsDescripColumn,
+		productFields,
 	)
 
 	stmt, err := tx.Prepare(query)
@@ -161,7 +141,7 @@ func (s *Storage) Save(ctx context.Context, product *models.Product) (int64, err
 		return 0, fmt.Errorf("%w: %w", ErrPrepareStmt, err)
 	}
 
-	result, err := stmt.Exec(product.Title, product.Price, product.Description)
+	result, err := stmt.Exec(productArgs(product)...)
 	if err != nil {
 
 		return 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
@@ -193,225 +173,529 @@ func (s *Storage) Save(ctx context.Context, product *models.Product) (int64, err
 
 	stmt2.Close()
 
-	//3
+	err = savePictures(ctx, tx, id, product)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// AdoptVk saves the product of an item found on the VK market linked to the
+// item, with the name it's published with, in one transaction.
+func (s *Storage) AdoptVk(ctx context.Context, product *models.Product, vkProductID int, name string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
+	}
+
+	defer tx.Rollback()
+
+	id, err := insertProduct(ctx, tx, product)
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = TRUE WHERE %s = ?", productsTable, productsVKLoadedColumn, productsIdColumn)
+
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	query = fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ? WHERE %s = ?",
+		productsPlatformIDsTable,
+		productsPlatformIDsVK,
+		productsPlatformIDsVKName,
+		productsIDkey,
+	)
+
+	_, err = tx.ExecContext(ctx, query, vkProductID, name, id)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
 
 	err = tx.Commit()
 	if err != nil {
 		return 0, fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
 	}
+
 	return id, nil
 }
 
-func (s *Storage) VkLoaded(productID int64, vkProductID int) error {
-	tx, err := s.db.BeginTx(context.TODO(), nil)
+// Update saves the fields of the product and enqueues jobs in the same transaction.
+func (s *Storage) Update(ctx context.Context, product *models.Product, jobs ...*models.Job) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
 	}
 
-	query1 := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsTable, productsVKLoadedColumn, productsIdColumn)
-	stmt, err := tx.Prepare(query1)
+	defer tx.Rollback()
+
+	product.Title = strings.ToLower(product.Title)
+
+	query := fmt.Sprintf(
+		`UPDATE %s SET (%s) = (LOWER(?), ?, ?, ?, ?, ?, ?, ?, ?, ?) WHERE %s = ? AND %s IS NULL`,
+		productsTable,
+		productFields,
+		productsIdColumn,
+		productsDeletedAtColumn,
+	)
+
+	result, err := tx.ExecContext(ctx, query, append(productArgs(product), product.Id)...)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrPrepareStmt, err)
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
 	}
 
-	_, err = stmt.Exec(true, productID)
+	n, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("%w:%w", ErrExecStmt, err)
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
 	}
 
-	query2 := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsPlatformIDsTable, productsPlatformIDsVK, productsIDkey)
-
-	stmt, err = tx.Prepare(query2)
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrPrepareStmt, err)
+	if n == 0 {
+		return storage.ErrProductIDnotFound
 	}
-	_, err = stmt.Exec(vkProductID, productID)
+
+	err = savePictures(ctx, tx, product.Id, product)
 	if err != nil {
-		return fmt.Errorf("%w:%w", ErrExecStmt, err)
+		return err
 	}
 
-	stmt.Close()
+	for _, job := range jobs {
+		job.ProductID = product.Id
+
+		job.ID, err = insertJob(ctx, tx, job)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
 	}
 
-	defer tx.Rollback()
+	return nil
+}
+
+// Product returns the stored product with its state on every platform, a
+// deleted product is not found.
+func (s *Storage) Product(ctx context.Context, productID int64) (*models.Product, error) {
+	return product(ctx, s.db, productID)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func product(ctx context.Context, q queryer, productID int64) (*models.Product, error) {
+	query := fmt.Sprintf(`
+	SELECT p.%s, p.%s, COALESCE(p.%s, ''), p.%s, p.%s, p.%s, p.%s,
+		p.%s, p.%s, p.%s, COALESCE(p.%s, ''),
+		p.%s, p.%s, p.%s,
+		COALESCE(i.%s, 0), COALESCE(i.%s, 0), COALESCE(i.%s, 0)
+	FROM %s p
+	LEFT JOIN %s i ON i.%s = p.%s
+	WHERE p.%s = ? AND p.%s IS NULL`,
+		productsTitleColumm, productsPriceColumn, productsDescripColumn, productsSizeColumn, productsStatusColumn,
+		productsVKCategoryColumn, productsUcozCategoryColumn,
+		productsVKToLoadColumn, productsUcozToLoadColumn, productsAvitoToLoadColumn, productsCreatedAtColumn,
+		productsVKLoadedColumn, productsUcozLoadedColumn, productsAvitoLoadedColumn,
+		productsPlatformIDsVK, productsPlatformIDsUcoz, productsPlatformIDsAvito,
+		productsTable,
+		productsPlatformIDsTable, productsIDkey, productsIdColumn,
+		productsIdColumn, productsDeletedAtColumn,
+	)
+
+	p := models.Product{Id: productID}
+
+	err := q.QueryRowContext(ctx, query, productID).Scan(
+		&p.Title,
+		&p.Price,
+		&p.Description,
+		&p.Size,
+		&p.Status,
+		&p.VK.CategoryID,
+		&p.Ucoz.CategoryID,
+		&p.VK.ToLoad,
+		&p.Ucoz.ToLoad,
+		&p.Avito.ToLoad,
+		&p.CreatedAt,
+		&p.VK.Loaded,
+		&p.Ucoz.Loaded,
+		&p.Avito.Loaded,
+		&p.VK.ProductID,
+		&p.Ucoz.ProductID,
+		&p.Avito.ProductID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrProductIDnotFound
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	err = loadPictures(ctx, q, &p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// productArgs are the values of productFields.
+func productArgs(p *models.Product) []any {
+	return []any{
+		p.Title, p.Price, p.Description, p.Size, p.Status,
+		p.VK.CategoryID, p.Ucoz.CategoryID,
+		p.VK.ToLoad, p.Ucoz.ToLoad, p.Avito.ToLoad,
+	}
+}
+
+func (s *Storage) VkLoaded(productID int64, vkProductID int) error {
+	return s.setPlatformState(productID, productsVKLoadedColumn, productsPlatformIDsVK, true, vkProductID)
+}
+
+// SetVkName saves the name the product is published with on the VK market.
+func (s *Storage) SetVkName(productID int64, name string) error {
+	query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsPlatformIDsTable, productsPlatformIDsVKName, productsIDkey)
+
+	_, err := s.db.Exec(query, name, productID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
 
 	return nil
 }
 
 func (s *Storage) VkDeleted(productID int64) error {
+	return s.setPlatformState(productID, productsVKLoadedColumn, productsPlatformIDsVK, false, 0)
+}
+
+func (s *Storage) UcozLoaded(productID int64, ucozProductID int) error {
+	return s.setPlatformState(productID, productsUcozLoadedColumn, productsPlatformIDsUcoz, true, ucozProductID)
+}
+
+func (s *Storage) UcozDeleted(productID int64) error {
+	return s.setPlatformState(productID, productsUcozLoadedColumn, productsPlatformIDsUcoz, false, 0)
+}
+
+func (s *Storage) AvitoLoaded(productID int64, avitoProductID int) error {
+	return s.setPlatformState(productID, productsAvitoLoadedColumn, productsPlatformIDsAvito, true, avitoProductID)
+}
+
+func (s *Storage) AvitoDeleted(productID int64) error {
+	return s.setPlatformState(productID, productsAvitoLoadedColumn, productsPlatformIDsAvito, false, 0)
+}
+
+// setPlatformState sets the loaded flag of the product and its ID on the platform in one transaction.
+func (s *Storage) setPlatformState(productID int64, loadedColumn string, platformIDColumn string, loaded bool, platformProductID int) error {
 	tx, err := s.db.BeginTx(context.TODO(), nil)
 	if err != nil {
 		return fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
 	}
 
-	query1 := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsTable, productsVKLoadedColumn, productsIdColumn)
-	stmt, err := tx.Prepare(query1)
+	defer tx.Rollback()
+
+	query1 := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsTable, loadedColumn, productsIdColumn)
+
+	_, err = tx.Exec(query1, loaded, productID)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrPrepareStmt, err)
+		return fmt.Errorf("%w:%w", ErrExecStmt, err)
 	}
 
-	_, err = stmt.Exec(false, productID)
+	query2 := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsPlatformIDsTable, platformIDColumn, productsIDkey)
+
+	_, err = tx.Exec(query2, platformProductID, productID)
 	if err != nil {
 		return fmt.Errorf("%w:%w", ErrExecStmt, err)
 	}
 
-	query2 := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsPlatformIDsTable, productsPlatformIDsVK, productsIDkey)
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
+	}
+
+	return nil
+}
+
+// Delete marks the product as deleted, cancels its queued add and update jobs
+// and enqueues the delete jobs in the same transaction.
+func (s *Storage) Delete(ctx context.Context, productID int64, jobs ...*models.Job) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
+	}
+
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = datetime('now') WHERE %s = ? AND %s IS NULL",
+		productsTable,
+		productsDeletedAtColumn,
+		productsIdColumn,
+		productsDeletedAtColumn,
+	)
+
+	result, err := tx.ExecContext(ctx, query, productID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
 
-	stmt, err = tx.Prepare(query2)
+	n, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrPrepareStmt, err)
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
 	}
-	_, err = stmt.Exec(0, productID)
+
+	if n == 0 {
+		return storage.ErrProductIDnotFound
+	}
+
+	err = cancelJobs(ctx, tx, productID, nil, "product deleted")
 	if err != nil {
-		return fmt.Errorf("%w:%w", ErrExecStmt, err)
+		return err
 	}
 
-	stmt.Close()
+	for _, job := range jobs {
+		job.ProductID = productID
+
+		job.ID, err = insertJob(ctx, tx, job)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
 	}
 
-	defer tx.Rollback()
-
 	return nil
 }
 
-func (s *Storage) UcozLoaded(productID int64, ucozProductID int) error {
-	tx, err := s.db.BeginTx(context.TODO(), nil)
+// Unpublish cancels the queued add and update jobs of the product on the
+// platforms and enqueues the delete jobs, the product itself is kept. The
+// product is no longer to be loaded to the platforms, so a later update
+// doesn't publish it again.
+func (s *Storage) Unpublish(ctx context.Context, productID int64, platforms []string, jobs ...*models.Job) error {
+	var toLoad []string
+
+	for _, platform := range platforms {
+		column, ok := toLoadColumns[platform]
+		if !ok {
+			return fmt.Errorf("unknown platform: %s", platform)
+		}
+
+		toLoad = append(toLoad, column+" = FALSE")
+	}
+
+	if len(toLoad) == 0 {
+		return fmt.Errorf("no platforms to unpublish")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
 	}
 
-	query1 := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsTable, productsUcozLoadedColumn, productsIdColumn)
-	stmt, err := tx.Prepare(query1)
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE %s = ? AND %s IS NULL",
+		productsTable,
+		strings.Join(toLoad, ", "),
+		productsIdColumn,
+		productsDeletedAtColumn,
+	)
+
+	result, err := tx.ExecContext(ctx, query, productID)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrPrepareStmt, err)
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
 	}
 
-	_, err = stmt.Exec(true, productID)
+	n, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("%w:%w", ErrExecStmt, err)
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
 	}
 
-	query2 := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", productsPlatformIDsTable, productsPlatformIDsUcoz, productsIDkey)
-
-	stmt, err = tx.Prepare(query2)
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrPrepareStmt, err)
+	if n == 0 {
+		return storage.ErrProductIDnotFound
 	}
-	_, err = stmt.Exec(ucozProductID, productID)
+
+	err = cancelJobs(ctx, tx, productID, platforms, "product unpublished")
 	if err != nil {
-		return fmt.Errorf("%w:%w", ErrExecStmt, err)
+		return err
 	}
 
-	stmt.Close()
+	for _, job := range jobs {
+		job.ProductID = productID
+
+		job.ID, err = insertJob(ctx, tx, job)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
 	}
 
-	defer tx.Rollback()
-
 	return nil
 }
 
-func (s *Storage) Delete(ctx context.Context, productID int) error {
-	return nil
+func (s *Storage) VkProductID(productID int64) (int, error) {
+	return s.platformProductID(productID, productsPlatformIDsVK)
 }
 
-func (s *Storage) countProducts(searchQuery string) (int, error) {
+func (s *Storage) UcozProductID(productID int64) (int, error) {
+	return s.platformProductID(productID, productsPlatformIDsUcoz)
+}
 
-	var count int
+func (s *Storage) AvitoProductID(productID int64) (int, error) {
+	return s.platformProductID(productID, productsPlatformIDsAvito)
+}
 
-	searchQuery = strings.ToLower(searchQuery)
+// Withdrawn reports whether the product is deleted or no longer to be loaded
+// to the platform and returns its ID there, 0 if it is not loaded there.
+func (s *Storage) Withdrawn(ctx context.Context, productID int64, platform string) (platformProductID int, withdrawn bool, err error) {
+	toLoadColumn, ok := toLoadColumns[platform]
+	if !ok {
+		return 0, false, fmt.Errorf("unknown platform: %s", platform)
+	}
 
 	query := fmt.Sprintf(`
-        SELECT COUNT(*) 
-        FROM %s 
-        WHERE %s MATCH ?
-        AND (%s = TRUE OR %s = TRUE OR %s = TRUE)`,
-		productsFtsTable,
-		productsTitleColumm,
-		productsUcozLoadedColumn,
-		productsVKLoadedColumn,
-		productsAvitoLoadedColumn,
+	SELECT COALESCE(ids.%s, 0), p.%s IS NOT NULL OR p.%s = FALSE
+	FROM %s p
+	LEFT JOIN %s ids ON ids.%s = p.%s
+	WHERE p.%s = ?`,
+		platformIDColumns[platform],
+		productsDeletedAtColumn,
+		toLoadColumn,
+		productsTable,
+		productsPlatformIDsTable, productsIDkey, productsIdColumn,
+		productsIdColumn,
 	)
 
-	err := s.db.QueryRow(query, searchQuery).Scan(&count)
+	err = s.db.QueryRowContext(ctx, query, productID).Scan(&platformProductID, &withdrawn)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, fmt.Errorf("no products found found")
-
+			return 0, false, storage.ErrProductIDnotFound
 		}
 
-		return 0, fmt.Errorf("failed to make query:%w", err)
+		return 0, false, fmt.Errorf("%w: %w", ErrExecStmt, err)
 	}
 
-	return count, nil
-
+	return platformProductID, withdrawn, nil
 }
 
-func (s *Storage) VkProductID(productID int64) (int, error) {
+// platformProductID returns the ID of the product on the platform, 0 if it is not loaded there.
+func (s *Storage) platformProductID(productID int64, platformIDColumn string) (int, error) {
 
 	if productID < 1 {
-		return 0, fmt.Errorf("productID can't be less 3")
+		return 0, fmt.Errorf("productID can't be less 1")
 	}
 
 	query := fmt.Sprintf(`
-	SELECT %s
+	SELECT COALESCE(%s, 0)
 	FROM %s
 	WHERE %s = ?`,
-		productsPlatformIDsVK,
+		platformIDColumn,
 		productsPlatformIDsTable,
 		productsIDkey,
 	)
 
-	var VkProductID int
+	var platformProductID int
 
-	err := s.db.QueryRow(query, productID).Scan(&VkProductID)
+	err := s.db.QueryRow(query, productID).Scan(&platformProductID)
 	if err != nil {
 
 		return 0, err
 	}
 
-	return VkProductID, nil
+	return platformProductID, nil
 
 }
 
-func (s *Storage) Search(ctx context.Context, searchQuery string, offset int, limit int) (products []*models.Product, count int, err error) {
+// PlatformLinks returns the products that are loaded to the platform or have its product ID.
+func (s *Storage) PlatformLinks(ctx context.Context, platform string) ([]models.PlatformLink, error) {
+	loadedColumn, ok := loadedColumns[platform]
+	if !ok {
+		return nil, fmt.Errorf("unknown platform: %s", platform)
+	}
+
+	platformIDColumn := platformIDColumns[platform]
+
+	// only VK keeps the published name
+	nameColumn := "NULL"
+	if platform == models.PlatformVK {
+		nameColumn = "ids." + productsPlatformIDsVKName
+	}
 
-	tx, err := s.db.BeginTx(context.TODO(), &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true})
+	query := fmt.Sprintf(`
+	SELECT p.%[1]s, COALESCE(ids.%[2]s, 0), p.%[3]s, p.%[7]s IS NOT NULL, COALESCE(%[8]s, '')
+	FROM %[4]s p
+	JOIN %[5]s ids ON ids.%[6]s = p.%[1]s
+	WHERE COALESCE(ids.%[2]s, 0) != 0 OR p.%[3]s = TRUE
+	ORDER BY p.%[1]s`,
+		productsIdColumn,
+		platformIDColumn,
+		loadedColumn,
+		productsTable,
+		productsPlatformIDsTable,
+		productsIDkey,
+		productsDeletedAtColumn,
+		nameColumn,
+	)
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	var links []models.PlatformLink
+
+	for rows.Next() {
+		var link models.PlatformLink
+
+		if err := rows.Scan(&link.ProductID, &link.PlatformProductID, &link.Loaded, &link.Deleted, &link.Name); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+		}
+
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return links, nil
+}
+
+// Search returns a page of products matching the options and the number of all matching products.
+func (s *Storage) Search(ctx context.Context, options *filters.Options, offset int, limit int) (products []*models.Product, count int, err error) {
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
 		return nil, 0, fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
 	}
 
 	defer tx.Rollback()
 
-	searchQuery = strings.ToLower(searchQuery)
+	where, args := filter(options)
 
-	querySearchIDs := fmt.Sprintf(
-		` 
-		SELECT rowid 
-        FROM %s
-        WHERE %s MATCH ?
-        AND (%s = TRUE OR %s = TRUE OR %s = TRUE)
-		LIMIT %d OFFSET %d
-`,
-		productsFtsTable,
-		productsTitleColumm,
-		productsAvitoLoadedColumn, productsVKLoadedColumn, productsUcozLoadedColumn,
-		limit, offset,
-	)
+	queryCount := fmt.Sprintf("SELECT COUNT(*) FROM %s p%s", productsTable, where)
 
-	count, err = s.countProducts(searchQuery)
+	err = tx.QueryRowContext(ctx, queryCount, args...).Scan(&count)
 	if err != nil {
+		if malformedMatch(err) {
+			return nil, 0, fmt.Errorf("%w: %w", storage.ErrInvalidSearch, err)
+		}
+
 		return nil, 0, fmt.Errorf("failed to check count: %w", err)
 	}
 
@@ -419,61 +703,55 @@ func (s *Storage) Search(ctx context.Context, searchQuery string, offset int, li
 		return nil, 0, sql.ErrNoRows
 	}
 
-	IDsRows, err := tx.Query(querySearchIDs, searchQuery)
+	querySearchIDs := fmt.Sprintf(
+		"SELECT p.%s FROM %s p%s ORDER BY %s LIMIT ? OFFSET ?",
+		productsIdColumn,
+		productsTable,
+		where,
+		orderBy(options),
+	)
+
+	IDsRows, err := tx.QueryContext(ctx, querySearchIDs, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
 	}
 
 	defer IDsRows.Close()
 
-	if err := IDsRows.Err(); err != nil {
-		log.Printf("ROWS ITERATION ERROR: %v", err)
-	}
-
-	var list []*models.Product
-
-	var id int
+	var ids []int64
 
-	// TODO  ВОТ ТУТ ПОЧЕМУ ТО НЕ СКАНИУРЕТСЯ НИХУЯ БЛЯТЬ И НЕ ПЕРЕХОДИТ, ХОТЯ Я СДЕЛАЛ ТАКОЙ ЖЕ ЗАПРОС В КВЕРИ ТУЛЕ И ТАМ ВСЕ НОРМ почини блтяь
 	for IDsRows.Next() {
+		var id int64
 
 		err = IDsRows.Scan(&id)
 		if err != nil {
-			s.log.Error("failed to get product by id", "error", err)
-			continue
+			return nil, 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
 		}
 
-		query := fmt.Sprintf(`
-		SELECT %s, %s, %s, %s, %s 
-		FROM %s
-		WHERE %s = ?`,
-			productsTitleColumm, productsDescripColumn, productsVKLoadedColumn, productsAvitoLoadedColumn, productsUcozLoadedColumn,
-			productsTable,
-			productsIdColumn)
-
-		var p models.Product
+		ids = append(ids, id)
+	}
 
-		p.Id = int64(id)
+	if err := IDsRows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
 
-		err = tx.QueryRow(query, id).Scan(
-			&p.Title,
-			&p.Description,
+	IDsRows.Close()
 
-			&p.VK.ToLoad,
-			&p.Avito.ToLoad,
-			&p.Ucoz.ToLoad,
-		)
+	var list []*models.Product
 
-		list = append(list, &p)
+	for _, id := range ids {
+		p, err := product(ctx, tx, id)
+		if err != nil {
+			return nil, 0, err
+		}
 
+		list = append(list, p)
 	}
 
-	if IDsRows.Err() != nil {
-		return nil, 0, err
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
 	}
 
-	tx.Commit()
-
 	return list, count, nil
 
 }
@@ -499,31 +777,183 @@ func (s *Storage) Ping() error {
 	return nil
 }
 
-// func filter(query string, options *filters.Options) (string, []interface{}) {
-// 	var whereClauses []string
-// 	var args []interface{}
-// 	argNum := 1
-
-// 	if options.CategoryID != nil {
-// 		whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", categoryIDColumn, argNum))
-// 		args = append(args, *options.CategoryID)
-// 		argNum++
-// 	}
-// 	if options.ProductID != nil {
-// 		whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", productIDColumn, argNum))
-// 		args = append(args, *options.ProductID)
-// 		argNum++
-// 	}
-// 	if options.Created_at != nil {
-// 		whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", createdAtColumn, argNum))
-// 		args = append(args, *options.Created_at)
-// 		argNum++
-// 	}
-
-// 	// Добавляем WHERE если есть условия
-// 	if len(whereClauses) > 0 {
-// 		query += " WHERE " + strings.Join(whereClauses, " AND ")
-// 	}
-
-// 	return query, args
-// }
+// malformedMatch reports whether err is a syntax error of the full-text search
+// query, e.g. an unbalanced quote.
+func malformedMatch(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrError &&
+		strings.HasPrefix(sqliteErr.Error(), "malformed MATCH expression")
+}
+
+// filter builds the WHERE clause of the listing with bound parameters,
+// deleted products are never listed.
+func filter(options *filters.Options) (string, []any) {
+	whereClauses := []string{fmt.Sprintf("p.%s IS NULL", productsDeletedAtColumn)}
+	var args []any
+
+	if options == nil {
+		return " WHERE " + whereClauses[0], nil
+	}
+
+	if options.Search != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf(
+			"p.%s IN (SELECT docid FROM %s WHERE %s MATCH ?)",
+			productsIdColumn, productsFtsTable, productsTitleColumm,
+		))
+		args = append(args, strings.ToLower(options.Search))
+	}
+	if options.ProductID != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s = ?", productsIdColumn))
+		args = append(args, *options.ProductID)
+	}
+	if options.CategoryID != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("(p.%s = ? OR p.%s = ?)", productsVKCategoryColumn, productsUcozCategoryColumn))
+		args = append(args, *options.CategoryID, *options.CategoryID)
+	}
+	if options.IsVkPublished != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s = ?", productsVKLoadedColumn))
+		args = append(args, *options.IsVkPublished)
+	}
+	if options.IsAvitoPublished != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s = ?", productsAvitoLoadedColumn))
+		args = append(args, *options.IsAvitoPublished)
+	}
+	if options.IsUcozPublished != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s = ?", productsUcozLoadedColumn))
+		args = append(args, *options.IsUcozPublished)
+	}
+	if options.PriceMin != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s >= ?", productsPriceColumn))
+		args = append(args, *options.PriceMin)
+	}
+	if options.PriceMax != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s <= ?", productsPriceColumn))
+		args = append(args, *options.PriceMax)
+	}
+	if options.CreatedFrom != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s >= ?", productsCreatedAtColumn))
+		args = append(args, options.CreatedFrom.UTC().Format(timeLayout))
+	}
+	if options.CreatedTo != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s <= ?", productsCreatedAtColumn))
+		args = append(args, options.CreatedTo.UTC().Format(timeLayout))
+	}
+	if options.Status != nil {
+		whereClauses = append(whereClauses, fmt.Sprintf("p.%s = ?", productsStatusColumn))
+		args = append(args, *options.Status)
+	}
+
+	if options.PublishState != "" && options.PublishState != filters.PublishStateAny {
+		clause, clauseArgs := publishState(options.PublishState, options.Platform)
+		whereClauses = append(whereClauses, clause)
+		args = append(args, clauseArgs...)
+	}
+
+	return " WHERE " + strings.Join(whereClauses, " AND "), args
+}
+
+// loadedColumns are the loaded flags of products by platform.
+var loadedColumns = map[string]string{
+	models.PlatformVK:    productsVKLoadedColumn,
+	models.PlatformUcoz:  productsUcozLoadedColumn,
+	models.PlatformAvito: productsAvitoLoadedColumn,
+}
+
+// toLoadColumns are the flags of products to be loaded by platform.
+var toLoadColumns = map[string]string{
+	models.PlatformVK:    productsVKToLoadColumn,
+	models.PlatformUcoz:  productsUcozToLoadColumn,
+	models.PlatformAvito: productsAvitoToLoadColumn,
+}
+
+// platformIDColumns are the columns of product IDs on platforms.
+var platformIDColumns = map[string]string{
+	models.PlatformVK:    productsPlatformIDsVK,
+	models.PlatformUcoz:  productsPlatformIDsUcoz,
+	models.PlatformAvito: productsPlatformIDsAvito,
+}
+
+// publishState builds the condition of the publish state on the platform, on
+// any platform when it is empty.
+func publishState(state string, platform string) (string, []any) {
+	platforms := []string{models.PlatformVK, models.PlatformUcoz, models.PlatformAvito}
+	if platform != "" {
+		platforms = []string{platform}
+	}
+
+	var loaded []string
+	var platformArgs []any
+
+	for _, platform := range platforms {
+		if column, ok := loadedColumns[platform]; ok {
+			loaded = append(loaded, fmt.Sprintf("p.%s = TRUE", column))
+		}
+		platformArgs = append(platformArgs, platform)
+	}
+
+	if len(loaded) == 0 {
+		loaded = append(loaded, "FALSE")
+	}
+
+	published := "(" + strings.Join(loaded, " OR ") + ")"
+
+	pending := fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM %[1]s o
+		WHERE o.%[2]s = p.%[3]s AND o.%[4]s IN (%[5]s) AND o.%[6]s IN (?, ?)
+	)`,
+		outboxTable, outboxProductIdColumn, productsIdColumn, outboxPlatformColumn,
+		placeholders(len(platforms)), outboxStatusColumn,
+	)
+	pendingArgs := append(append([]any{}, platformArgs...), models.JobStatusQueued, models.JobStatusProcessing)
+
+	// only the last job of the platform counts, a later successful one fixes the failure
+	failed := fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM %[1]s o
+		WHERE o.%[2]s = p.%[3]s AND o.%[4]s IN (%[5]s) AND o.%[6]s = ?
+		AND o.%[7]s = (
+			SELECT MAX(l.%[7]s) FROM %[1]s l
+			WHERE l.%[2]s = o.%[2]s AND l.%[4]s = o.%[4]s
+		)
+	)`,
+		outboxTable, outboxProductIdColumn, productsIdColumn, outboxPlatformColumn,
+		placeholders(len(platforms)), outboxStatusColumn, outboxIdColumn,
+	)
+	failedArgs := append(append([]any{}, platformArgs...), models.JobStatusDead)
+
+	switch state {
+	case filters.PublishStatePublished:
+		return published, nil
+	case filters.PublishStatePending:
+		return pending, pendingArgs
+	case filters.PublishStateFailed:
+		return failed, failedArgs
+	default:
+		args := append(pendingArgs, failedArgs...)
+		return fmt.Sprintf("(NOT %s AND NOT %s AND NOT %s)", published, pending, failed), args
+	}
+}
+
+// orderBy maps the sort option to a column, unknown fields sort by ID.
+func orderBy(options *filters.Options) string {
+	column := productsIdColumn
+
+	if options != nil {
+		switch options.Sort {
+		case filters.SortPrice:
+			column = productsPriceColumn
+		case filters.SortCreatedAt:
+			column = productsCreatedAtColumn
+		case filters.SortTitle:
+			column = productsTitleColumm
+		}
+	}
+
+	direction := "ASC"
+	if options != nil && options.SortDesc {
+		direction = "DESC"
+	}
+
+	// ID keeps the order of equal values stable between pages
+	return fmt.Sprintf("p.%s %s, p.%s %s", column, direction, productsIdColumn, direction)
+}
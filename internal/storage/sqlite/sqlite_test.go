@@ -0,0 +1,370 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"prodLoaderREST/internal/domain/filters"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/storage"
+	"slices"
+	"testing"
+	"time"
+)
+
+func saveProduct(t *testing.T, s *Storage, title string, price int, vkCategory int) int64 {
+	t.Helper()
+
+	id, err := s.Save(context.Background(), &models.Product{
+		Title:          title,
+		Description:    title,
+		Size:           "42",
+		Status:         "new",
+		Price:          price,
+		MainPictureURL: "http://pics/" + title,
+		VK:             models.VK{ToLoad: true, CategoryID: vkCategory},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+// search returns the IDs of the listed products in their order.
+func search(t *testing.T, s *Storage, options *filters.Options) []int64 {
+	t.Helper()
+
+	products, count, err := s.Search(context.Background(), options, 0, 10)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]int64, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.Id)
+	}
+
+	if count != len(ids) {
+		t.Fatalf("count %d doesn't match %d products", count, len(ids))
+	}
+
+	return ids
+}
+
+func TestSearchFilters(t *testing.T) {
+	s := newTestStorage(t)
+
+	shoes := saveProduct(t, s, "белые кеды", 3000, 1)
+	boots := saveProduct(t, s, "черные ботинки", 7000, 2)
+	sneakers := saveProduct(t, s, "белые кроссовки", 5000, 1)
+
+	for _, id := range []int64{shoes, boots, sneakers} {
+		if err := s.VkLoaded(id, int(id)*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.UcozLoaded(boots, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.db.Exec("UPDATE products SET created_at = datetime('now', '-2 days') WHERE id = ?", shoes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	category := 1
+	minPrice, maxPrice := 4000, 6000
+	ucoz := true
+	weekAgo, dayAgo := time.Now().Add(-7*24*time.Hour), time.Now().Add(-24*time.Hour)
+	status := "new"
+
+	tests := []struct {
+		name    string
+		options *filters.Options
+		want    []int64
+	}{
+		{"no options", nil, []int64{shoes, boots, sneakers}},
+		{"search", &filters.Options{Search: "Белые"}, []int64{shoes, sneakers}},
+		{"category", &filters.Options{CategoryID: &category}, []int64{shoes, sneakers}},
+		{"price range", &filters.Options{PriceMin: &minPrice, PriceMax: &maxPrice}, []int64{sneakers}},
+		{"published on ucoz", &filters.Options{IsUcozPublished: &ucoz}, []int64{boots}},
+		{"created range", &filters.Options{CreatedFrom: &weekAgo, CreatedTo: &dayAgo}, []int64{shoes}},
+		{"status", &filters.Options{Status: &status}, []int64{shoes, boots, sneakers}},
+		{"combined", &filters.Options{Search: "белые", PriceMin: &minPrice}, []int64{sneakers}},
+		{"sort by price desc", &filters.Options{Sort: filters.SortPrice, SortDesc: true}, []int64{boots, sneakers, shoes}},
+		{"sort by title", &filters.Options{Sort: filters.SortTitle}, []int64{shoes, sneakers, boots}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := search(t, s, tt.options); !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	s := newTestStorage(t)
+
+	var ids []int64
+	for _, title := range []string{"a", "b", "c"} {
+		id := saveProduct(t, s, title, 100, 1)
+		if err := s.VkLoaded(id, int(id)); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	products, count, err := s.Search(context.Background(), nil, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the count is of all matching products, not of the page
+	if count != 3 || len(products) != 1 || products[0].Id != ids[1] {
+		t.Fatalf("expected the second of 3 products, got %d of %d", len(products), count)
+	}
+}
+
+func TestSearchMalformed(t *testing.T) {
+	s := newTestStorage(t)
+
+	_, _, err := s.Search(context.Background(), &filters.Options{Search: `"кеды`}, 0, 10)
+	if !errors.Is(err, storage.ErrInvalidSearch) {
+		t.Fatalf("expected ErrInvalidSearch, got %v", err)
+	}
+}
+
+// failJob leases the job of the platform and moves it to the dead-letter list.
+func failJob(t *testing.T, s *Storage, productID int64, platform string) {
+	t.Helper()
+
+	id := enqueue(t, s, productID, platform, models.JobActionAdd)
+	leased := lease(t, s, platform)[0]
+
+	if err := s.BuryJob(context.Background(), id, leased.Attempts, "failed"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchPublishState(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	published := saveProduct(t, s, "published", 100, 1)
+	pending := saveProduct(t, s, "pending", 100, 1)
+	failed := saveProduct(t, s, "failed", 100, 1)
+	fixed := saveProduct(t, s, "fixed", 100, 1)
+	unpublished := saveProduct(t, s, "unpublished", 100, 1)
+	onUcoz := saveProduct(t, s, "ucoz", 100, 1)
+
+	if err := s.VkLoaded(published, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UcozLoaded(onUcoz, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	enqueue(t, s, pending, models.PlatformVK, models.JobActionAdd)
+
+	failJob(t, s, failed, models.PlatformVK)
+
+	// a later successful job fixes the failure
+	failJob(t, s, fixed, models.PlatformVK)
+
+	id := enqueue(t, s, fixed, models.PlatformVK, models.JobActionAdd)
+	if err := s.CompleteJob(ctx, id, lease(t, s, models.PlatformVK)[0].Attempts); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		state    string
+		platform string
+		want     []int64
+	}{
+		{"unpublished products are listed", "", "", []int64{published, pending, failed, fixed, unpublished, onUcoz}},
+		{"any", filters.PublishStateAny, "", []int64{published, pending, failed, fixed, unpublished, onUcoz}},
+		{"published", filters.PublishStatePublished, "", []int64{published, onUcoz}},
+		{"published on vk", filters.PublishStatePublished, models.PlatformVK, []int64{published}},
+		{"pending", filters.PublishStatePending, "", []int64{pending}},
+		{"pending on ucoz", filters.PublishStatePending, models.PlatformUcoz, nil},
+		{"failed", filters.PublishStateFailed, "", []int64{failed}},
+		{"unpublished", filters.PublishStateUnpublished, "", []int64{fixed, unpublished}},
+		{"unpublished on vk", filters.PublishStateUnpublished, models.PlatformVK, []int64{fixed, unpublished, onUcoz}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := search(t, s, &filters.Options{PublishState: tt.state, Platform: tt.platform})
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id := saveProduct(t, s, "кеды", 100, 1)
+	kept := saveProduct(t, s, "ботинки", 100, 1)
+
+	add := enqueue(t, s, id, models.PlatformVK, models.JobActionAdd)
+	update := enqueue(t, s, id, models.PlatformUcoz, models.JobActionUpdate)
+	other := enqueue(t, s, kept, models.PlatformVK, models.JobActionAdd)
+
+	if err := s.Delete(ctx, id, &models.Job{Platform: models.PlatformVK, Action: models.JobActionDelete}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, jobID := range []int64{add, update} {
+		if j := job(t, s, jobID); j.Status != models.JobStatusCancelled || j.Stage != models.JobStageCancelled {
+			t.Fatalf("expected job %d cancelled, got %s %s", jobID, j.Status, j.Stage)
+		}
+	}
+
+	if j := job(t, s, other); j.Status != models.JobStatusQueued {
+		t.Fatalf("expected the job of another product queued, got %s", j.Status)
+	}
+
+	// the delete job comes with the delete and is not cancelled
+	jobs := lease(t, s, models.PlatformVK)
+	if len(jobs) != 2 || jobs[0].ID != other || jobs[1].Action != models.JobActionDelete {
+		t.Fatalf("expected the other add and the delete job, got %v", jobs)
+	}
+
+	if _, err := s.Product(ctx, id); !errors.Is(err, storage.ErrProductIDnotFound) {
+		t.Fatalf("expected a deleted product not found, got %v", err)
+	}
+
+	if got := search(t, s, nil); !slices.Equal(got, []int64{kept}) {
+		t.Fatalf("expected a deleted product not listed, got %v", got)
+	}
+
+	if err := s.Delete(ctx, id); !errors.Is(err, storage.ErrProductIDnotFound) {
+		t.Fatalf("expected ErrProductIDnotFound deleting twice, got %v", err)
+	}
+}
+
+func TestUnpublish(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id := saveProduct(t, s, "кеды", 100, 1)
+
+	vk := enqueue(t, s, id, models.PlatformVK, models.JobActionUpdate)
+	ucoz := enqueue(t, s, id, models.PlatformUcoz, models.JobActionAdd)
+
+	if err := s.Unpublish(ctx, id, []string{models.PlatformVK}); err != nil {
+		t.Fatal(err)
+	}
+
+	if j := job(t, s, vk); j.Status != models.JobStatusCancelled {
+		t.Fatalf("expected the vk job cancelled, got %s", j.Status)
+	}
+
+	if j := job(t, s, ucoz); j.Status != models.JobStatusQueued {
+		t.Fatalf("expected the ucoz job queued, got %s", j.Status)
+	}
+
+	product, err := s.Product(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the product stays off vk until it is asked to load again
+	if product.VK.ToLoad {
+		t.Fatal("expected vk not to load")
+	}
+
+	if err := s.Unpublish(ctx, id, []string{"ebay"}); err == nil {
+		t.Fatal("expected an unknown platform refused")
+	}
+}
+
+func TestWithdrawn(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	id := saveProduct(t, s, "кеды", 100, 1)
+
+	if err := s.VkLoaded(id, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	vkID, withdrawn, err := s.Withdrawn(ctx, id, models.PlatformVK)
+	if err != nil || vkID != 42 || withdrawn {
+		t.Fatalf("expected a listed product on vk 42, got %d %t %v", vkID, withdrawn, err)
+	}
+
+	if err := s.Unpublish(ctx, id, []string{models.PlatformVK}); err != nil {
+		t.Fatal(err)
+	}
+
+	vkID, withdrawn, err = s.Withdrawn(ctx, id, models.PlatformVK)
+	if err != nil || vkID != 42 || !withdrawn {
+		t.Fatalf("expected an unpublished product withdrawn, got %d %t %v", vkID, withdrawn, err)
+	}
+
+	deleted := saveProduct(t, s, "ботинки", 100, 1)
+
+	if err := s.Delete(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, withdrawn, err := s.Withdrawn(ctx, deleted, models.PlatformVK); err != nil || !withdrawn {
+		t.Fatalf("expected a deleted product withdrawn, got %t %v", withdrawn, err)
+	}
+
+	if _, _, err := s.Withdrawn(ctx, 100, models.PlatformVK); !errors.Is(err, storage.ErrProductIDnotFound) {
+		t.Fatalf("expected ErrProductIDnotFound, got %v", err)
+	}
+}
+
+func TestEnqueueWithdraw(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	withdraw := func() bool {
+		t.Helper()
+
+		ok, err := s.EnqueueWithdraw(ctx, &models.Job{ProductID: 1, Platform: models.PlatformVK, Action: models.JobActionDelete})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ok
+	}
+
+	if !withdraw() {
+		t.Fatal("expected a withdraw enqueued")
+	}
+
+	// a pending withdraw is not enqueued twice, whether queued or processing
+	if withdraw() {
+		t.Fatal("expected a queued withdraw not duplicated")
+	}
+
+	leased := lease(t, s, models.PlatformVK)[0]
+
+	if withdraw() {
+		t.Fatal("expected a processing withdraw not duplicated")
+	}
+
+	if err := s.CompleteJob(ctx, leased.ID, leased.Attempts); err != nil {
+		t.Fatal(err)
+	}
+
+	if !withdraw() {
+		t.Fatal("expected a withdraw enqueued after the last one is done")
+	}
+}
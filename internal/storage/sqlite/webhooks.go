@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"prodLoaderREST/internal/domain/models"
+	"prodLoaderREST/internal/storage"
+	"time"
+)
+
+var (
+	webhookDeliveriesTable        = "webhook_deliveries"
+	webhookDeliveriesId           = "id"
+	webhookDeliveriesEvent        = "event"
+	webhookDeliveriesURL          = "url"
+	webhookDeliveriesPayload      = "payload"
+	webhookDeliveriesStatus       = "status"
+	webhookDeliveriesAttempts     = "attempts"
+	webhookDeliveriesResponseCode = "response_code"
+	webhookDeliveriesLastError    = "last_error"
+	webhookDeliveriesAvailableAt  = "available_at"
+	webhookDeliveriesCreatedAt    = "created_at"
+	webhookDeliveriesUpdatedAt    = "updated_at"
+	webhookDeliveriesFields       = fmt.Sprintf("%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s",
+		webhookDeliveriesId, webhookDeliveriesEvent, webhookDeliveriesURL, webhookDeliveriesPayload,
+		webhookDeliveriesStatus, webhookDeliveriesAttempts, webhookDeliveriesResponseCode, webhookDeliveriesLastError,
+		webhookDeliveriesAvailableAt, webhookDeliveriesCreatedAt, webhookDeliveriesUpdatedAt)
+)
+
+// SaveWebhookDeliveries queues the deliveries in one transaction and sets their IDs.
+func (s *Storage) SaveWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w:%w", storage.ErrBeginTx, err)
+	}
+
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s(%s, %s, %s, %s) VALUES (?, ?, ?, ?)`,
+		webhookDeliveriesTable,
+		webhookDeliveriesEvent, webhookDeliveriesURL, webhookDeliveriesPayload, webhookDeliveriesStatus,
+	)
+
+	for _, d := range deliveries {
+		result, err := tx.ExecContext(ctx, query, d.Event, d.URL, string(d.Payload), models.WebhookStatusPending)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrExecStmt, err)
+		}
+
+		d.ID, err = result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("%w:%w", storage.ErrReturnId, err)
+		}
+
+		d.Status = models.WebhookStatusPending
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%w: %w", storage.ErrCommitTx, err)
+	}
+
+	return nil
+}
+
+// DueWebhookDeliveries returns up to limit pending deliveries that are due, the oldest first.
+func (s *Storage) DueWebhookDeliveries(ctx context.Context, limit int) ([]*models.WebhookDelivery, error) {
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s = ? AND (%s IS NULL OR %s <= datetime('now')) ORDER BY %s LIMIT ?",
+		webhookDeliveriesFields,
+		webhookDeliveriesTable,
+		webhookDeliveriesStatus,
+		webhookDeliveriesAvailableAt,
+		webhookDeliveriesAvailableAt,
+		webhookDeliveriesId,
+	)
+
+	return s.webhookDeliveries(ctx, query, models.WebhookStatusPending, limit)
+}
+
+// UpdateWebhookDelivery saves the result of an attempt, a pending delivery
+// becomes available again after delay.
+func (s *Storage) UpdateWebhookDelivery(ctx context.Context, d *models.WebhookDelivery, delay time.Duration) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = ?, %s = ?, %s = ?, %s = datetime('now', ?), %s = datetime('now') WHERE %s = ?",
+		webhookDeliveriesTable,
+		webhookDeliveriesStatus,
+		webhookDeliveriesAttempts,
+		webhookDeliveriesResponseCode,
+		webhookDeliveriesLastError,
+		webhookDeliveriesAvailableAt,
+		webhookDeliveriesUpdatedAt,
+		webhookDeliveriesId,
+	)
+
+	return s.execWebhookUpdate(ctx, query, d.Status, d.Attempts, d.ResponseCode, d.LastError, secondsModifier(delay), d.ID)
+}
+
+// RedeliverWebhook returns a failed delivery to the queue with a fresh attempt counter.
+func (s *Storage) RedeliverWebhook(ctx context.Context, deliveryID int64) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = ?, %s = 0, %s = NULL, %s = datetime('now') WHERE %s = ? AND %s = ?",
+		webhookDeliveriesTable,
+		webhookDeliveriesStatus,
+		webhookDeliveriesAttempts,
+		webhookDeliveriesAvailableAt,
+		webhookDeliveriesUpdatedAt,
+		webhookDeliveriesId,
+		webhookDeliveriesStatus,
+	)
+
+	return s.execWebhookUpdate(ctx, query, models.WebhookStatusPending, deliveryID, models.WebhookStatusFailed)
+}
+
+// WebhookDeliveries returns a page of the delivery log, the latest first,
+// only the deliveries with the status when it's not empty.
+func (s *Storage) WebhookDeliveries(ctx context.Context, status string, offset int, limit int) (deliveries []*models.WebhookDelivery, count int, err error) {
+	where := ""
+	var args []any
+
+	if status != "" {
+		where = fmt.Sprintf(" WHERE %s = ?", webhookDeliveriesStatus)
+		args = append(args, status)
+	}
+
+	queryCount := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", webhookDeliveriesTable, where)
+
+	err = s.db.QueryRowContext(ctx, queryCount, args...).Scan(&count)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to check count: %w", err)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s%s ORDER BY %s DESC LIMIT ? OFFSET ?",
+		webhookDeliveriesFields,
+		webhookDeliveriesTable,
+		where,
+		webhookDeliveriesId,
+	)
+
+	deliveries, err = s.webhookDeliveries(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, count, nil
+}
+
+func (s *Storage) webhookDeliveries(ctx context.Context, query string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+
+	for rows.Next() {
+		var (
+			d           models.WebhookDelivery
+			payload     string
+			availableAt sql.NullString
+		)
+
+		err := rows.Scan(
+			&d.ID,
+			&d.Event,
+			&d.URL,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseCode,
+			&d.LastError,
+			&availableAt,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+		}
+
+		d.Payload = []byte(payload)
+		d.AvailableAt = availableAt.String
+
+		deliveries = append(deliveries, &d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) execWebhookUpdate(ctx context.Context, query string, args ...any) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecStmt, err)
+	}
+
+	if n == 0 {
+		return storage.ErrWebhookDeliveryNotFound
+	}
+
+	return nil
+}
@@ -3,24 +3,80 @@ package storage
 import (
 	"context"
 	"errors"
+	"prodLoaderREST/internal/domain/filters"
 	"prodLoaderREST/internal/domain/models"
+	"time"
 )
 
 type Storage interface {
-	Save(ctx context.Context, product *models.Product) (int64, error)
+	Save(ctx context.Context, product *models.Product, jobs ...*models.Job) (int64, error)
+	Update(ctx context.Context, product *models.Product, jobs ...*models.Job) error
+	Delete(ctx context.Context, productID int64, jobs ...*models.Job) error
+	Unpublish(ctx context.Context, productID int64, platforms []string, jobs ...*models.Job) error
+	Product(ctx context.Context, productID int64) (*models.Product, error)
 	VkProductID(productID int64) (int, error)
-	Search(ctx context.Context, searchQuery string, offset int, limit int) (products []*models.Product, count int, err error)
+	UcozProductID(productID int64) (int, error)
+	Search(ctx context.Context, options *filters.Options, offset int, limit int) (products []*models.Product, count int, err error)
+	EachProduct(ctx context.Context, fn func(p *models.Product) error) error
 	UcozLoaded(productID int64, ucozProductID int) error
+	UcozDeleted(productID int64) error
+	AvitoProductID(productID int64) (int, error)
+	AvitoLoaded(productID int64, avitoProductID int) error
+	AvitoDeleted(productID int64) error
+	AddToAvitoFeed(ctx context.Context, product *models.Product) error
+	RemoveFromAvitoFeed(ctx context.Context, productID int64) error
+	InAvitoFeed(ctx context.Context, productID int64) (bool, error)
+	AvitoFeed(ctx context.Context) ([]*models.Product, error)
+	PlatformLinks(ctx context.Context, platform string) ([]models.PlatformLink, error)
 	VkLoaded(productID int64, vkProductID int) error
+	SetVkName(productID int64, name string) error
+	AdoptVk(ctx context.Context, product *models.Product, vkProductID int, name string) (int64, error)
 	VkDeleted(productID int64) error
+	Withdrawn(ctx context.Context, productID int64, platform string) (platformProductID int, withdrawn bool, err error)
+
+	EnqueueJob(ctx context.Context, job *models.Job) (int64, error)
+	EnqueueWithdraw(ctx context.Context, job *models.Job) (bool, error)
+	LeaseJobs(ctx context.Context, platforms []string, limit int, lease time.Duration) ([]*models.Job, error)
+	CompleteJob(ctx context.Context, jobID int64, attempt int) error
+	SetJobStage(ctx context.Context, jobID int64, attempt int, stage string, lease time.Duration) error
+	RetryJob(ctx context.Context, jobID int64, attempt int, reason string, delay time.Duration) error
+	BuryJob(ctx context.Context, jobID int64, attempt int, reason string) error
+	RequeueJobs(ctx context.Context) (int64, error)
+	DeadJobs(ctx context.Context, offset int, limit int) (jobs []*models.Job, count int, err error)
+	RedriveJob(ctx context.Context, jobID int64) error
+	Job(ctx context.Context, jobID int64) (*models.Job, error)
+	ProductJobs(ctx context.Context, productID int64) ([]*models.Job, error)
+
+	SaveImportBatch(ctx context.Context, batch *models.ImportBatch) error
+	UpdateImportBatch(ctx context.Context, batch *models.ImportBatch) error
+	ImportBatch(ctx context.Context, batchID int64) (*models.ImportBatch, error)
+	InterruptImports(ctx context.Context) (int64, error)
+
+	SaveWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error
+	DueWebhookDeliveries(ctx context.Context, limit int) ([]*models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery, delay time.Duration) error
+	RedeliverWebhook(ctx context.Context, deliveryID int64) error
+	WebhookDeliveries(ctx context.Context, status string, offset int, limit int) (deliveries []*models.WebhookDelivery, count int, err error)
+
+	SetPictureKeys(ctx context.Context, productID int64, keys []string) error
+	PictureKey(ctx context.Context, productID int64, position int) (string, error)
+	ClearPictureKeys(ctx context.Context, productID int64) ([]string, error)
+	PictureKeyUsed(ctx context.Context, key string) (bool, error)
+
 	Close() error
 	Ping() error
 }
 
 var (
-	ErrProductIDExists   = errors.New("product ID already exists in storage")
-	ErrProductIDnotFound = errors.New("product ID not found in storage")
-	ErrReturnId          = errors.New("failed to return id of product ")
-	ErrBeginTx           = errors.New("failed to begin transaction")
-	ErrCommitTx          = errors.New("failed to commit transaction")
+	ErrProductIDExists         = errors.New("product ID already exists in storage")
+	ErrProductIDnotFound       = errors.New("product ID not found in storage")
+	ErrJobNotFound             = errors.New("job not found in storage")
+	ErrJobLeaseLost            = errors.New("job lease is lost")
+	ErrImportBatchNotFound     = errors.New("import batch not found in storage")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found in storage")
+	ErrPictureNotFound         = errors.New("picture not found in storage")
+	ErrInvalidSearch           = errors.New("invalid search query")
+	ErrReturnId                = errors.New("failed to return id of product ")
+	ErrBeginTx                 = errors.New("failed to begin transaction")
+	ErrCommitTx                = errors.New("failed to commit transaction")
 )